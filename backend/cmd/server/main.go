@@ -2,23 +2,24 @@ package main
 
 import (
 	"context"
-	"database/sql"
-	"fmt"
+	"errors"
 	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
 	"time"
 
 	"github.com/gant123/jobTracker/internal/config"
 	"github.com/gant123/jobTracker/internal/crypto"
 	"github.com/gant123/jobTracker/internal/database"
 	"github.com/gant123/jobTracker/internal/handlers"
+	"github.com/gant123/jobTracker/internal/jobs"
 	"github.com/gant123/jobTracker/internal/middleware"
-	"github.com/gant123/jobTracker/internal/models"
 	"github.com/gant123/jobTracker/internal/repository"
 	"github.com/gant123/jobTracker/internal/services"
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
-	gmail "google.golang.org/api/gmail/v1"
-	"google.golang.org/api/option"
 )
 
 func main() {
@@ -48,22 +49,80 @@ func main() {
 	// Initialize repositories
 	userRepo := repository.NewUserRepository(db)
 	jobRepo := repository.NewJobRepository(db)
+	jobEmailRepo := repository.NewJobEmailRepository(db)
+	contactRepo := repository.NewJobContactRepository(db)
 	jobQueueRepo := repository.NewJobQueueRepository(db)
 	gmailSyncRepo := repository.NewGmailSyncRepository(db)
+	classifierRepo := repository.NewClassifierRepository(db)
+	scanFilterRepo := repository.NewScanFilterRepository(db)
+	reclassifyRepo := repository.NewReclassifyRepository(db)
+	messageCacheRepo := repository.NewMessageCacheRepository(db)
+	quotaRepo := repository.NewQuotaRepository(db)
+	scheduleRepo := repository.NewScheduleRepository(db)
 	// Initialize services
 	authService := services.NewAuthService(userRepo, cfg)
-	jobService := services.NewJobService(jobRepo)
-	// google oauth handler
+	classifierService := services.NewClassifierService(classifierRepo, jobQueueRepo)
+	scanProfiles := services.NewScanProfiles(classifierService, scanFilterRepo, messageCacheRepo)
+	reclassifyService := services.NewReclassifyService(reclassifyRepo, jobQueueRepo, scanProfiles)
+	postingService := services.NewPostingService(jobRepo, jobQueueRepo)
+	jobService := services.NewJobService(jobRepo, jobEmailRepo, contactRepo, classifierService, scanFilterRepo, postingService)
+	// google oauth handler; every Gmail call is metered against the quota
+	gmailQuota := services.NewGmailQuota(quotaRepo, cfg)
 	googleOAuth := services.NewGoogleOAuth()
-	googleHandler := handlers.NewGoogleHandler(googleOAuth, logger, tokenRepo, jobRepo, jobQueueRepo, gmailSyncRepo)
+	googleOAuth.Quota = gmailQuota
+	googleHandler := handlers.NewGoogleHandler(googleOAuth, logger, tokenRepo, jobRepo, jobEmailRepo, jobQueueRepo, gmailSyncRepo, classifierService, scanProfiles, scanFilterRepo, reclassifyService, messageCacheRepo, scheduleRepo)
 	// Initialize handlers
 	authHandler := handlers.NewAuthHandler(authService, logger)
 	jobHandler := handlers.NewJobHandler(jobService, logger)
 	healthHandler := handlers.NewHealthHandler(db)
-	worker := NewWorker(db, logger, tokenRepo, jobRepo, jobQueueRepo, gmailSyncRepo)
-	go worker.Start()
+	adminHandler := handlers.NewAdminHandler(gmailQuota, jobQueueRepo, logger)
+	backgroundJobHandler := handlers.NewBackgroundJobHandler(jobQueueRepo, logger)
+	// Background jobs
+	registry := jobs.NewRegistry(jobQueueRepo)
+	jobs.Register(registry, services.TrainClassifierJob, classifierService.RunTraining)
+	jobs.Register(registry, services.PostingSalaryJob, postingService.RunJob)
+	services.NewGmailJobs(googleOAuth, tokenRepo, jobRepo, jobEmailRepo, contactRepo, gmailSyncRepo, scanProfiles, reclassifyService, postingService, jobQueueRepo).Register(registry)
+	worker := jobs.NewWorker(jobQueueRepo, registry, logger)
+	worker.Listen(database.ConnString(cfg))
+	jobs.Register(registry, jobs.PruneJob, worker.Prune)
+	retention := jobs.Retention{Jobs: 7 * 24 * time.Hour, Logs: 30 * 24 * time.Hour}
+	if d, err := time.ParseDuration(cfg.JobRetention); err == nil {
+		retention.Jobs = d
+	}
+	if d, err := time.ParseDuration(cfg.LogRetention); err == nil {
+		retention.Logs = d
+	}
+	scheduler := jobs.NewScheduler(scheduleRepo, jobQueueRepo, registry, logger)
+	if err := jobs.System(scheduler, services.GmailRescanJob, "0 3 * * *", jobs.None{}); err != nil {
+		logger.Fatal("Failed to schedule system jobs:", err)
+	}
+	if err := jobs.System(scheduler, jobs.PruneJob, "30 4 * * *", retention); err != nil {
+		logger.Fatal("Failed to schedule system jobs:", err)
+	}
+	scheduleHandler := handlers.NewScheduleHandler(scheduler, registry, scheduleRepo, logger)
+	// SIGTERM stops the HTTP server and the worker together.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	concurrency, err := strconv.Atoi(cfg.Workers)
+	if err != nil || concurrency < 1 {
+		concurrency = 4
+	}
+	if perUser, err := strconv.Atoi(cfg.JobsPerUser); err == nil {
+		jobQueueRepo.SetUserConcurrency(perUser)
+	}
+	grace, err := time.ParseDuration(cfg.ShutdownGrace)
+	if err != nil {
+		grace = 25 * time.Second
+	}
+	workerDone := make(chan struct{})
+	go func() {
+		defer close(workerDone)
+		worker.Run(ctx, concurrency, grace)
+	}()
+	go scheduler.Run(ctx)
+	go gmailQuota.Run(ctx, 30*time.Second)
 	// Setup routes
-	router := setupRoutes(authHandler, jobHandler, healthHandler, googleHandler, cfg, logger)
+	router := setupRoutes(authHandler, jobHandler, healthHandler, googleHandler, adminHandler, scheduleHandler, backgroundJobHandler, cfg, logger)
 
 	// Start server
 	port := cfg.Port
@@ -71,10 +130,24 @@ func main() {
 		port = "8080"
 	}
 
-	logger.Infof("Server starting on port %s", port)
-	if err := http.ListenAndServe(":"+port, router); err != nil {
-		logger.Fatal("Server failed to start:", err)
+	srv := &http.Server{Addr: ":" + port, Handler: router}
+	go func() {
+		logger.Infof("Server starting on port %s", port)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Fatal("Server failed to start:", err)
+		}
+	}()
+
+	<-ctx.Done()
+	logger.Info("Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		logger.WithError(err).Warn("HTTP server did not shut down cleanly")
 	}
+	<-workerDone
+	// Don't lose the usage metered since the last periodic flush.
+	gmailQuota.Flush()
 }
 
 func setupRoutes(
@@ -82,6 +155,9 @@ func setupRoutes(
 	jobHandler *handlers.JobHandler,
 	healthHandler *handlers.HealthHandler,
 	googleHandler *handlers.GoogleHandler,
+	adminHandler *handlers.AdminHandler,
+	scheduleHandler *handlers.ScheduleHandler,
+	backgroundJobHandler *handlers.BackgroundJobHandler,
 	cfg *config.Config,
 	logger *logrus.Logger,
 ) *mux.Router {
@@ -116,6 +192,18 @@ func setupRoutes(
 	protected.HandleFunc("/google/disconnect", googleHandler.Disconnect).Methods(http.MethodPost)
 	protected.HandleFunc("/google/scan", googleHandler.Scan).Methods(http.MethodGet)
 	protected.HandleFunc("/google/sync-status", googleHandler.SyncStatus).Methods("GET")
+	protected.HandleFunc("/google/jobs/{id}/thread", googleHandler.JobThread).Methods("GET")
+	protected.HandleFunc("/google/classifier", googleHandler.ClassifierInfo).Methods("GET")
+	protected.HandleFunc("/google/sender-rules", googleHandler.ListSenderRules).Methods("GET")
+	protected.HandleFunc("/google/sender-rules", googleHandler.SaveSenderRule).Methods("POST")
+	protected.HandleFunc("/google/sender-rules/{id}", googleHandler.DeleteSenderRule).Methods("DELETE")
+	protected.HandleFunc("/google/languages", googleHandler.ListLanguages).Methods("GET")
+	protected.HandleFunc("/google/languages", googleHandler.SaveLanguages).Methods("PUT")
+	protected.HandleFunc("/google/reclassify", googleHandler.ReclassifyProposals).Methods("GET")
+	protected.HandleFunc("/google/reclassify", googleHandler.StartReclassify).Methods("POST")
+	protected.HandleFunc("/google/reclassify", googleHandler.DiscardReclassify).Methods("DELETE")
+	protected.HandleFunc("/google/reclassify/apply", googleHandler.ApplyReclassify).Methods("POST")
+	protected.HandleFunc("/google/quota", googleHandler.Quota).Methods("GET")
 	// protected (requires logged-in user)
 	protected.HandleFunc("/google/scan", googleHandler.Scan).Methods("GET")
 	// Jobs routes
@@ -124,177 +212,30 @@ func setupRoutes(
 	protected.HandleFunc("/jobs/{id}", jobHandler.GetJob).Methods("GET")
 	protected.HandleFunc("/jobs/{id}", jobHandler.UpdateJob).Methods("PUT")
 	protected.HandleFunc("/jobs/{id}", jobHandler.DeleteJob).Methods("DELETE")
+	protected.HandleFunc("/jobs/{id}/emails", jobHandler.GetJobEmails).Methods("GET")
+	protected.HandleFunc("/jobs/{id}/contacts", jobHandler.GetJobContacts).Methods("GET")
+	protected.HandleFunc("/jobs/{id}/not-a-job", jobHandler.NotAJob).Methods("POST")
+	// Scheduled background jobs
+	protected.HandleFunc("/schedules", scheduleHandler.List).Methods("GET")
+	protected.HandleFunc("/schedules", scheduleHandler.Create).Methods("POST")
+	protected.HandleFunc("/schedules/{id}", scheduleHandler.Delete).Methods("DELETE")
+	protected.HandleFunc("/background-jobs", backgroundJobHandler.List).Methods("GET")
+	protected.HandleFunc("/background-jobs/{id}/cancel", backgroundJobHandler.Cancel).Methods("POST")
 
 	// User routes
 	protected.HandleFunc("/auth/me", authHandler.GetProfile).Methods("GET")
 	protected.HandleFunc("/auth/logout", authHandler.Logout).Methods("POST")
 
-	return r
-}
-
-type Worker struct {
-	db           *sql.DB
-	logger       *logrus.Logger
-	tokenRepo    repository.TokenRepository
-	jobRepo      *repository.JobRepository
-	jobQueueRepo *repository.JobQueueRepository
-	syncRepo     *repository.GmailSyncRepository
-}
-
-func NewWorker(
-	db *sql.DB,
-	logger *logrus.Logger,
-	tokenRepo repository.TokenRepository,
-	jobRepo *repository.JobRepository,
-	jobQueueRepo *repository.JobQueueRepository,
-	syncRepo *repository.GmailSyncRepository,
-) *Worker {
-	return &Worker{
-		db:           db,
-		logger:       logger,
-		tokenRepo:    tokenRepo,
-		jobRepo:      jobRepo,
-		jobQueueRepo: jobQueueRepo,
-		syncRepo:     syncRepo,
-	}
-}
-
-func (w *Worker) Start() {
-	w.logger.Info("Starting background worker")
-
-	ticker := time.NewTicker(5 * time.Second)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ticker.C:
-			w.processNextJob()
-		}
-	}
-}
-
-func (w *Worker) processNextJob() {
-	job, err := w.jobQueueRepo.GetNextJob()
-	if err == sql.ErrNoRows {
-		return // No jobs
-	}
-	if err != nil {
-		w.logger.WithError(err).Error("Failed to get next job")
-		return
-	}
-
-	w.logger.WithFields(logrus.Fields{
-		"job_id":   job.ID,
-		"type":     job.Type,
-		"user_id":  job.UserID,
-		"attempts": job.Attempts,
-	}).Info("Processing job")
-
-	switch job.Type {
-	case "gmail_initial_sync":
-		err = w.processInitialSync(job.UserID)
-	default:
-		w.logger.Warn("Unknown job type", "type", job.Type)
-		return
-	}
-
-	if err != nil {
-		w.logger.WithError(err).Error("Job failed")
-		w.jobQueueRepo.MarkJobFailed(job.ID, err.Error())
-	} else {
-		w.jobQueueRepo.MarkJobComplete(job.ID)
-	}
-}
-
-func (w *Worker) processInitialSync(userID int) error {
-	w.logger.Info("Starting initial Gmail sync", "user_id", userID)
-
-	// Get Gmail token
-	ctx := context.Background()
-	tok, err := w.tokenRepo.Get(ctx, userID, "gmail")
-	if err != nil {
-		return fmt.Errorf("failed to get token: %w", err)
-	}
-
-	// Create Gmail service
-	oauth := services.NewGoogleOAuth()
-	client := oauth.Client(ctx, tok)
-	srv, err := gmail.NewService(ctx, option.WithHTTPClient(client))
-	if err != nil {
-		return fmt.Errorf("failed to create gmail service: %w", err)
-	}
-
-	// Mark sync started
-	w.syncRepo.UpdateSyncStarted(userID)
-
-	// Get existing job IDs to avoid duplicates
-	existingIDs, err := w.jobRepo.GetAllGmailMessageIDsByUserID(userID)
-	if err != nil {
-		return fmt.Errorf("failed to get existing IDs: %w", err)
-	}
-
-	// Scan last year of emails
-	scanner := services.NewGmailScanner()
-	since := time.Now().AddDate(-1, 0, 0)
-	until := time.Now()
-
-	totalImported := 0
-	pageToken := ""
+	// Admin routes
+	admin := protected.PathPrefix("/admin").Subrouter()
+	admin.Use(middleware.Admin(cfg))
+	admin.HandleFunc("/gmail/quota", adminHandler.GmailQuota).Methods("GET")
+	admin.HandleFunc("/background-jobs", adminHandler.BackgroundJobs).Methods("GET")
+	admin.HandleFunc("/background-jobs/summary", adminHandler.QueueSummary).Methods("GET")
+	admin.HandleFunc("/background-jobs/replay", adminHandler.ReplayDeadJobs).Methods("POST")
+	admin.HandleFunc("/background-jobs/{id}/retry", adminHandler.RetryBackgroundJob).Methods("POST")
+	admin.HandleFunc("/background-jobs/{id}/replay", adminHandler.ReplayBackgroundJob).Methods("POST")
+	admin.HandleFunc("/background-jobs/{id}/attempts", adminHandler.BackgroundJobAttempts).Methods("GET")
 
-	for {
-		// Use your existing scanner
-		result, err := scanner.ScanPage(ctx, srv, since, until, 100, pageToken, "all", existingIDs)
-		if err != nil {
-			return fmt.Errorf("scan failed: %w", err)
-		}
-
-		// Import each job
-		for _, event := range result.Events {
-			jobData := &models.CreateJobRequest{
-				Company:        event.Company,
-				Position:       event.Title,
-				Status:         event.Status,
-				AppliedDate:    &event.AppliedDate,
-				Notes:          fmt.Sprintf("[Gmail Import] %s", event.Subject),
-				GmailMessageID: event.MessageID,
-			}
-
-			if jobData.Company == "" {
-				jobData.Company = "Unknown Company"
-			}
-			if jobData.Position == "" {
-				jobData.Position = "Unknown Position"
-			}
-
-			err := w.jobRepo.Create(&models.Job{
-				UserID:         userID,
-				Company:        jobData.Company,
-				Position:       jobData.Position,
-				Status:         jobData.Status,
-				AppliedDate:    jobData.AppliedDate,
-				Notes:          jobData.Notes,
-				GmailMessageID: jobData.GmailMessageID,
-			})
-
-			if err == nil {
-				totalImported++
-			}
-		}
-
-		if result.NextPageToken == "" {
-			break
-		}
-		pageToken = result.NextPageToken
-
-		// Be nice to Gmail API
-		time.Sleep(100 * time.Millisecond)
-	}
-
-	// Mark sync completed
-	w.syncRepo.UpdateSyncCompleted(userID, totalImported)
-
-	w.logger.Info("Initial sync completed", "user_id", userID, "total_imported", totalImported)
-
-	return nil
+	return r
 }
-
@@ -19,6 +19,17 @@ type Config struct {
 	JWTExpiry      string
 	AllowedOrigins string
 	EncryptionKey  string
+	AdminEmails    string // comma-separated; may use the admin API
+	Workers        string // background jobs run at once per instance
+	ShutdownGrace  string // how long running jobs get to finish after SIGTERM
+	JobsPerUser    string // background jobs one user may run at once, across instances
+	JobRetention   string // how long completed and cancelled jobs are kept; 0 keeps them
+	LogRetention   string // how long job attempt logs and dead jobs are kept; 0 keeps them
+	// Gmail API quota units; see GmailQuota
+	GmailUserUnitsPerSec   string
+	GmailUserDailyUnits    string // 0 means no per-user daily cap
+	GmailGlobalUnitsPerSec string
+	GmailDailyUnits        string
 }
 
 func Load() *Config {
@@ -38,6 +49,17 @@ func Load() *Config {
 		JWTExpiry:      getEnv("JWT_EXPIRY", "24h"),
 		AllowedOrigins: getEnv("ALLOWED_ORIGINS", "http://localhost:3000"),
 		EncryptionKey:  getEnv("ENCRYPTION_KEY", ""),
+		AdminEmails:    getEnv("ADMIN_EMAILS", ""),
+		Workers:        getEnv("WORKER_CONCURRENCY", "4"),
+		ShutdownGrace:  getEnv("SHUTDOWN_GRACE", "25s"),
+		JobsPerUser:    getEnv("JOBS_PER_USER", "1"),
+		JobRetention:   getEnv("JOB_RETENTION", "168h"),
+		LogRetention:   getEnv("JOB_LOG_RETENTION", "720h"),
+
+		GmailUserUnitsPerSec:   getEnv("GMAIL_USER_UNITS_PER_SEC", ""),
+		GmailUserDailyUnits:    getEnv("GMAIL_USER_DAILY_UNITS", ""),
+		GmailGlobalUnitsPerSec: getEnv("GMAIL_GLOBAL_UNITS_PER_SEC", ""),
+		GmailDailyUnits:        getEnv("GMAIL_DAILY_UNITS", ""),
 	}
 }
 
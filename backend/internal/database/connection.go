@@ -8,19 +8,20 @@ import (
 	_ "github.com/lib/pq"
 )
 
-func Initialize(cfg *config.Config) (*sql.DB, error) {
-	var connStr string
-
+// ConnString is the lib/pq connection string for cfg, also used for
+// connections outside the pool (e.g. LISTEN).
+func ConnString(cfg *config.Config) string {
 	if cfg.DatabaseURL != "" {
-		connStr = cfg.DatabaseURL
-	} else {
-		connStr = fmt.Sprintf(
-			"host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
-			cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBPassword, cfg.DBName, cfg.DBSSLMode,
-		)
+		return cfg.DatabaseURL
 	}
+	return fmt.Sprintf(
+		"host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
+		cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBPassword, cfg.DBName, cfg.DBSSLMode,
+	)
+}
 
-	db, err := sql.Open("postgres", connStr)
+func Initialize(cfg *config.Config) (*sql.DB, error) {
+	db, err := sql.Open("postgres", ConnString(cfg))
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
@@ -34,8 +34,7 @@ func Migrate(db *sql.DB) error {
             applied_date DATE,
             interview_date TIMESTAMP,
             created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-            updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-	    UNIQUE(gmail_message_id)
+            updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
         )`,
 		// Gmail/Email OAuth token storage (encrypted at rest)
 		`CREATE TABLE IF NOT EXISTS email_tokens (
@@ -80,6 +79,190 @@ func Migrate(db *sql.DB) error {
 		`CREATE INDEX IF NOT EXISTS idx_jobs_user_id ON jobs(user_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_jobs_status ON jobs(status)`,
 		`CREATE INDEX IF NOT EXISTS idx_users_email ON users(email)`,
+		// Set when Google answers invalid_grant; cleared by the next Save.
+		`ALTER TABLE email_tokens ADD COLUMN IF NOT EXISTS needs_reauth BOOLEAN DEFAULT FALSE`,
+		`ALTER TABLE email_tokens ADD COLUMN IF NOT EXISTS reauth_reason TEXT`,
+		// Source emails linked to a job (its correspondence timeline)
+		`CREATE TABLE IF NOT EXISTS job_emails (
+    id SERIAL PRIMARY KEY,
+    job_id INTEGER NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    gmail_message_id VARCHAR(255) NOT NULL,
+    thread_id VARCHAR(255) NOT NULL DEFAULT '',
+    subject TEXT NOT NULL DEFAULT '',
+    from_address TEXT NOT NULL DEFAULT '',
+    snippet TEXT NOT NULL DEFAULT '',
+    received_at TIMESTAMP,
+    direction VARCHAR(10) NOT NULL DEFAULT 'inbound',   -- inbound|outbound
+    classification VARCHAR(50) NOT NULL DEFAULT '',
+    link TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(job_id, gmail_message_id)
+)`,
+		`CREATE INDEX IF NOT EXISTS idx_job_emails_job_id ON job_emails(job_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_job_emails_user_message ON job_emails(user_id, gmail_message_id)`,
+		`CREATE TABLE IF NOT EXISTS job_contacts (
+    id SERIAL PRIMARY KEY,
+    job_id INTEGER NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL DEFAULT '',
+    email VARCHAR(255) NOT NULL,
+    role VARCHAR(50) NOT NULL DEFAULT '',                -- recipient|recruiter|...
+    source VARCHAR(20) NOT NULL DEFAULT 'manual',        -- gmail|manual
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(job_id, email)
+)`,
+		`CREATE INDEX IF NOT EXISTS idx_job_contacts_job_id ON job_contacts(job_id)`,
+		// One message can yield several jobs (a digest lists many postings), so
+		// uniqueness is per user, message and posting instead of per message.
+		`ALTER TABLE jobs ADD COLUMN IF NOT EXISTS source_key TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE jobs DROP CONSTRAINT IF EXISTS jobs_gmail_message_id_key`,
+		`UPDATE jobs SET gmail_message_id = NULL WHERE gmail_message_id = ''`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_jobs_user_gmail_source ON jobs(user_id, gmail_message_id, source_key)`,
+		// Per-user learning classifier: corrections are the training data
+		`CREATE TABLE IF NOT EXISTS classifier_corrections (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    job_id INTEGER REFERENCES jobs(id) ON DELETE SET NULL,
+    gmail_message_id VARCHAR(255) NOT NULL,
+    field VARCHAR(20) NOT NULL,                          -- status|company|position
+    old_value TEXT NOT NULL DEFAULT '',
+    new_value TEXT NOT NULL DEFAULT '',
+    subject TEXT NOT NULL DEFAULT '',
+    snippet TEXT NOT NULL DEFAULT '',
+    from_address TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+)`,
+		`CREATE INDEX IF NOT EXISTS idx_classifier_corrections_user ON classifier_corrections(user_id)`,
+		`CREATE TABLE IF NOT EXISTS classifier_models (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    examples INTEGER NOT NULL DEFAULT 0,
+    accuracy DOUBLE PRECISION NOT NULL DEFAULT 0,
+    model JSONB NOT NULL,
+    trained_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+)`,
+		// Per-user scan filters
+		`CREATE TABLE IF NOT EXISTS sender_rules (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    pattern VARCHAR(255) NOT NULL,                       -- address or domain
+    kind VARCHAR(10) NOT NULL,                           -- allow|block
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(user_id, pattern)
+)`,
+		`CREATE TABLE IF NOT EXISTS dismissed_messages (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    gmail_message_id VARCHAR(255) NOT NULL,
+    reason VARCHAR(50) NOT NULL DEFAULT 'not_a_job',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, gmail_message_id)
+)`,
+		`CREATE TABLE IF NOT EXISTS scan_settings (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    languages VARCHAR(100) NOT NULL DEFAULT 'en',
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+)`,
+		`CREATE TABLE IF NOT EXISTS job_field_edits (
+    job_id INTEGER NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
+    field VARCHAR(20) NOT NULL,
+    edited_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (job_id, field)
+)`,
+		// Corrections recorded before edits were tracked are hand edits too
+		`INSERT INTO job_field_edits (job_id, field)
+    SELECT DISTINCT job_id, field FROM classifier_corrections WHERE job_id IS NOT NULL
+    ON CONFLICT DO NOTHING`,
+		`CREATE TABLE IF NOT EXISTS reclassify_proposals (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    job_id INTEGER NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
+    field VARCHAR(20) NOT NULL,
+    old_value TEXT NOT NULL DEFAULT '',
+    new_value TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(job_id, field)
+)`,
+		`CREATE TABLE IF NOT EXISTS gmail_message_cache (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    gmail_message_id VARCHAR(255) NOT NULL,
+    thread_id VARCHAR(255) NOT NULL DEFAULT '',
+    subject TEXT NOT NULL DEFAULT '',
+    from_address TEXT NOT NULL DEFAULT '',
+    to_address TEXT NOT NULL DEFAULT '',
+    snippet TEXT NOT NULL DEFAULT '',
+    message_date TIMESTAMP,
+    classifier_version VARCHAR(100) NOT NULL DEFAULT '',
+    class VARCHAR(20) NOT NULL DEFAULT '',
+    events JSONB NOT NULL DEFAULT '[]',
+    fetched_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, gmail_message_id)
+)`,
+		`CREATE TABLE IF NOT EXISTS gmail_quota_usage (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    day DATE NOT NULL,
+    units BIGINT NOT NULL DEFAULT 0,
+    requests BIGINT NOT NULL DEFAULT 0,
+    throttled BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (user_id, day)
+)`,
+		`CREATE INDEX IF NOT EXISTS idx_gmail_quota_usage_day ON gmail_quota_usage(day)`,
+		`ALTER TABLE jobs ADD COLUMN IF NOT EXISTS salary_period VARCHAR(10) NOT NULL DEFAULT ''`,
+		// Set from the job type's retry policy when the job is queued.
+		`ALTER TABLE background_jobs ADD COLUMN IF NOT EXISTS max_attempts INTEGER NOT NULL DEFAULT 3`,
+		// Which worker holds a running job, and when it last said so.
+		`ALTER TABLE background_jobs ADD COLUMN IF NOT EXISTS worker_id VARCHAR(100)`,
+		`ALTER TABLE background_jobs ADD COLUMN IF NOT EXISTS heartbeat_at TIMESTAMP`,
+		`CREATE TABLE IF NOT EXISTS job_schedules (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE, -- NULL for system schedules
+    job_type VARCHAR(50) NOT NULL,
+    payload JSONB NOT NULL DEFAULT '{}',
+    cron VARCHAR(100) NOT NULL,
+    timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    next_run_at TIMESTAMP NOT NULL,                         -- UTC
+    last_run_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_job_schedules_system ON job_schedules(job_type) WHERE user_id IS NULL`,
+		`CREATE INDEX IF NOT EXISTS idx_job_schedules_due ON job_schedules(next_run_at) WHERE enabled`,
+		`CREATE INDEX IF NOT EXISTS idx_job_schedules_user ON job_schedules(user_id)`,
+		// At most one waiting or running job per (type, user, key)
+		`ALTER TABLE background_jobs ADD COLUMN IF NOT EXISTS unique_key VARCHAR(255)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_background_jobs_unique
+    ON background_jobs(type, COALESCE(user_id, 0), unique_key)
+    WHERE unique_key IS NOT NULL AND status IN ('pending', 'processing')`,
+		// Counting a user's running jobs when claiming
+		`CREATE INDEX IF NOT EXISTS idx_background_jobs_user_status ON background_jobs(user_id, status)`,
+		// -1 maintenance, 0 background, 1 interactive
+		`ALTER TABLE background_jobs ADD COLUMN IF NOT EXISTS priority SMALLINT NOT NULL DEFAULT 0`,
+		// One row per run of a job, kept after the job retries or dies
+		`CREATE TABLE IF NOT EXISTS background_job_attempts (
+    id SERIAL PRIMARY KEY,
+    job_id INTEGER NOT NULL REFERENCES background_jobs(id) ON DELETE CASCADE,
+    attempt INTEGER NOT NULL,
+    worker_id VARCHAR(100) NOT NULL,
+    started_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    finished_at TIMESTAMP,                 -- NULL while running
+    outcome VARCHAR(20),                   -- completed|failed|rescheduled|paused|interrupted|lost|cancelled
+    error TEXT
+)`,
+		`CREATE INDEX IF NOT EXISTS idx_background_job_attempts_job ON background_job_attempts(job_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_background_job_attempts_started ON background_job_attempts(started_at)`,
+		// Jobs that failed for good wait in the dead-letter state to be replayed.
+		// The check below keeps 'failed' from being written again, so after the
+		// first startup the update finds nothing.
+		`UPDATE background_jobs SET status = 'dead' WHERE status = 'failed'`,
+		`DO $$ BEGIN
+    ALTER TABLE background_jobs ADD CONSTRAINT background_jobs_status_check
+        CHECK (status IN ('pending', 'processing', 'completed', 'dead', 'paused', 'cancelled'));
+EXCEPTION WHEN duplicate_object THEN NULL;
+END $$`,
+		// Pruning finished jobs past their retention
+		`CREATE INDEX IF NOT EXISTS idx_background_jobs_finished ON background_jobs(updated_at)
+    WHERE status IN ('completed', 'cancelled', 'dead')`,
 	}
 
 	for _, migration := range migrations {
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gant123/jobTracker/internal/repository"
+	"github.com/gant123/jobTracker/internal/services"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// AdminHandler serves operator views across all users.
+type AdminHandler struct {
+	quota  *services.GmailQuota
+	queue  *repository.JobQueueRepository
+	logger *logrus.Logger
+}
+
+func NewAdminHandler(quota *services.GmailQuota, queue *repository.JobQueueRepository, logger *logrus.Logger) *AdminHandler {
+	return &AdminHandler{quota: quota, queue: queue, logger: logger}
+}
+
+// GET /api/admin/gmail/quota?limit=20  (ADMIN)
+// Who used the most Gmail quota today.
+func (h *AdminHandler) GmailQuota(w http.ResponseWriter, r *http.Request) {
+	limit := 20
+	if n, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && n > 0 && n <= 500 {
+		limit = n
+	}
+	users, err := h.quota.TopUsers(limit)
+	if err != nil {
+		h.logger.WithError(err).Error("failed to list quota usage")
+		http.Error(w, "failed to list quota usage", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"users": users})
+}
+
+// GET /api/admin/background-jobs?user_id=&type=&status=&limit=50&offset=0  (ADMIN)
+// Background jobs across all users, newest first.
+func (h *AdminHandler) BackgroundJobs(w http.ResponseWriter, r *http.Request) {
+	f, ok := queuedJobFilter(r)
+	if !ok {
+		http.Error(w, "unknown status", http.StatusBadRequest)
+		return
+	}
+	if v := r.URL.Query().Get("user_id"); v != "" {
+		uid, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "invalid user id", http.StatusBadRequest)
+			return
+		}
+		f.UserID = &uid
+	}
+	jobs, err := h.queue.ListJobs(f)
+	if err != nil {
+		h.logger.WithError(err).Error("failed to list background jobs")
+		http.Error(w, "failed to list background jobs", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"jobs": jobs})
+}
+
+// GET /api/admin/background-jobs/summary  (ADMIN)
+// Unfinished jobs per type, and how long the oldest due one has waited.
+func (h *AdminHandler) QueueSummary(w http.ResponseWriter, r *http.Request) {
+	depths, err := h.queue.QueueDepths()
+	if err != nil {
+		h.logger.WithError(err).Error("failed to count background jobs")
+		http.Error(w, "failed to count background jobs", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"types": depths})
+}
+
+// POST /api/admin/background-jobs/{id}/retry  (ADMIN)
+// Runs a pending, paused, dead or cancelled job right away, with one more
+// attempt if it was out of them.
+func (h *AdminHandler) RetryBackgroundJob(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid job id", http.StatusBadRequest)
+		return
+	}
+	if err := h.queue.RetryNow(id); err != nil {
+		if errors.Is(err, repository.ErrJobNotFound) {
+			http.Error(w, "no retryable job with that id", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, repository.ErrDuplicate) {
+			http.Error(w, "an identical job is already queued", http.StatusConflict)
+			return
+		}
+		h.logger.WithError(err).Error("failed to retry background job")
+		http.Error(w, "failed to retry job", http.StatusInternalServerError)
+		return
+	}
+	h.logger.WithField("job_id", id).Info("Background job retried by admin")
+	writeJSON(w, http.StatusOK, map[string]string{"status": "queued"})
+}
+
+// POST /api/admin/background-jobs/{id}/replay  (ADMIN)
+// Queues a dead job again with a fresh set of attempts.
+func (h *AdminHandler) ReplayBackgroundJob(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid job id", http.StatusBadRequest)
+		return
+	}
+	if err := h.queue.ReplayJob(id); err != nil {
+		if errors.Is(err, repository.ErrJobNotFound) {
+			http.Error(w, "no dead job with that id", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, repository.ErrDuplicate) {
+			http.Error(w, "an identical job is already queued", http.StatusConflict)
+			return
+		}
+		h.logger.WithError(err).Error("failed to replay background job")
+		http.Error(w, "failed to replay job", http.StatusInternalServerError)
+		return
+	}
+	h.logger.WithField("job_id", id).Info("Dead background job replayed by admin")
+	writeJSON(w, http.StatusOK, map[string]string{"status": "queued"})
+}
+
+// POST /api/admin/background-jobs/replay?type=gmail_initial_sync  (ADMIN)
+// Replays every dead job of a type, e.g. after fixing what killed them.
+func (h *AdminHandler) ReplayDeadJobs(w http.ResponseWriter, r *http.Request) {
+	jobType := r.URL.Query().Get("type")
+	if jobType == "" {
+		http.Error(w, "type is required", http.StatusBadRequest)
+		return
+	}
+	n, err := h.queue.ReplayDeadJobs(jobType)
+	if err != nil {
+		h.logger.WithError(err).Error("failed to replay dead background jobs")
+		http.Error(w, "failed to replay jobs", http.StatusInternalServerError)
+		return
+	}
+	h.logger.WithFields(logrus.Fields{"type": jobType, "jobs": n}).Info("Dead background jobs replayed by admin")
+	writeJSON(w, http.StatusOK, map[string]any{"replayed": n})
+}
+
+// GET /api/admin/background-jobs/{id}/attempts  (ADMIN)
+// When and where each run of a job happened and how it ended.
+func (h *AdminHandler) BackgroundJobAttempts(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid job id", http.StatusBadRequest)
+		return
+	}
+	attempts, err := h.queue.ListAttempts(id)
+	if err != nil {
+		h.logger.WithError(err).Error("failed to list job attempts")
+		http.Error(w, "failed to list job attempts", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"attempts": attempts})
+}
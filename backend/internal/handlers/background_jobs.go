@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gant123/jobTracker/internal/models"
+	"github.com/gant123/jobTracker/internal/repository"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// BackgroundJobHandler shows users the background work queued for them.
+type BackgroundJobHandler struct {
+	queue  *repository.JobQueueRepository
+	logger *logrus.Logger
+}
+
+func NewBackgroundJobHandler(queue *repository.JobQueueRepository, logger *logrus.Logger) *BackgroundJobHandler {
+	return &BackgroundJobHandler{queue: queue, logger: logger}
+}
+
+var jobStatuses = map[string]bool{
+	"pending": true, "processing": true, "completed": true,
+	"dead": true, "paused": true, "cancelled": true,
+}
+
+// queuedJobFilter reads ?type=&status=&limit=&offset=.
+func queuedJobFilter(r *http.Request) (models.QueuedJobFilter, bool) {
+	q := r.URL.Query()
+	f := models.QueuedJobFilter{Type: q.Get("type"), Status: q.Get("status")}
+	if f.Status != "" && !jobStatuses[f.Status] {
+		return f, false
+	}
+	f.Limit, _ = strconv.Atoi(q.Get("limit"))
+	f.Offset, _ = strconv.Atoi(q.Get("offset"))
+	return f, true
+}
+
+// GET /api/background-jobs?type=&status=&limit=50&offset=0  (PROTECTED)
+// The user's background jobs, newest first.
+func (h *BackgroundJobHandler) List(w http.ResponseWriter, r *http.Request) {
+	uid, err := userIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	f, ok := queuedJobFilter(r)
+	if !ok {
+		http.Error(w, "unknown status", http.StatusBadRequest)
+		return
+	}
+	f.UserID = &uid
+	jobs, err := h.queue.ListJobs(f)
+	if err != nil {
+		h.logger.WithError(err).Error("failed to list background jobs")
+		http.Error(w, "failed to list background jobs", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"jobs": jobs})
+}
+
+// POST /api/background-jobs/{id}/cancel  (PROTECTED)
+// Cancels a job that hasn't started yet.
+func (h *BackgroundJobHandler) Cancel(w http.ResponseWriter, r *http.Request) {
+	uid, err := userIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid job id", http.StatusBadRequest)
+		return
+	}
+	if err := h.queue.CancelJob(id, uid); err != nil {
+		if errors.Is(err, repository.ErrJobNotFound) {
+			http.Error(w, "no pending job with that id", http.StatusNotFound)
+			return
+		}
+		h.logger.WithError(err).Error("failed to cancel background job")
+		http.Error(w, "failed to cancel job", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "cancelled"})
+}
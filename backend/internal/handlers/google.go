@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -10,12 +11,13 @@ import (
 	"strconv"
 	"time"
 
-	//
-	// "github.com/gant123/jobTracker/internal/jobs"
+	"github.com/gant123/jobTracker/internal/models"
 	"github.com/gant123/jobTracker/internal/repository"
 	"github.com/gant123/jobTracker/internal/services"
+	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
 	gmail "google.golang.org/api/gmail/v1"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/option"
 )
 
@@ -24,24 +26,40 @@ const cookieUID = "g_uid"
 const cookieState = "g_state"
 
 type GoogleHandler struct {
-	OAuth     *services.GoogleOAuth
-	Logger    *logrus.Logger
-	TokenRepo repository.TokenRepository
-	Scanner   *services.GmailScanner
-	JobRepo   *repository.JobRepository
-	JobQueue  *repository.JobQueueRepository
-	SyncRepo  *repository.GmailSyncRepository
+	OAuth        *services.GoogleOAuth
+	Logger       *logrus.Logger
+	TokenRepo    repository.TokenRepository
+	Scanner      *services.GmailScanner
+	JobRepo      *repository.JobRepository
+	JobEmailRepo *repository.JobEmailRepository
+	JobQueue     *repository.JobQueueRepository
+	SyncRepo     *repository.GmailSyncRepository
+	Threads      *services.ThreadCache
+	Classifier   *services.ClassifierService
+	Profiles     *services.ScanProfiles
+	Filters      *repository.ScanFilterRepository
+	Reclassifier *services.ReclassifyService
+	MessageCache *repository.MessageCacheRepository
+	Schedules    *repository.ScheduleRepository
 }
 
-func NewGoogleHandler(o *services.GoogleOAuth, logger *logrus.Logger, tr repository.TokenRepository, jr *repository.JobRepository, jq *repository.JobQueueRepository, sr *repository.GmailSyncRepository) *GoogleHandler {
+func NewGoogleHandler(o *services.GoogleOAuth, logger *logrus.Logger, tr repository.TokenRepository, jr *repository.JobRepository, jer *repository.JobEmailRepository, jq *repository.JobQueueRepository, sr *repository.GmailSyncRepository, cs *services.ClassifierService, sp *services.ScanProfiles, fr *repository.ScanFilterRepository, rs *services.ReclassifyService, mc *repository.MessageCacheRepository, sch *repository.ScheduleRepository) *GoogleHandler {
 	return &GoogleHandler{
-		OAuth:     o,
-		Logger:    logger,
-		TokenRepo: tr,
-		Scanner:   services.NewGmailScanner(),
-		JobRepo:   jr,
-		JobQueue:  jq,
-		SyncRepo:  sr,
+		OAuth:        o,
+		Logger:       logger,
+		TokenRepo:    tr,
+		Scanner:      services.NewGmailScanner(),
+		JobRepo:      jr,
+		JobEmailRepo: jer,
+		JobQueue:     jq,
+		SyncRepo:     sr,
+		Threads:      services.NewThreadCache(5 * time.Minute),
+		Classifier:   cs,
+		Profiles:     sp,
+		Filters:      fr,
+		Reclassifier: rs,
+		MessageCache: mc,
+		Schedules:    sch,
 	}
 }
 
@@ -108,8 +126,15 @@ func (h *GoogleHandler) Callback(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Queue initial sync job
-	if err := h.JobQueue.CreateJob("gmail_initial_sync", uid, nil); err != nil {
+	// Anything parked while the grant was revoked can run again.
+	if err := h.JobQueue.ResumeUserJobs(uid); err != nil {
+		h.Logger.WithError(err).Warn("failed to resume paused jobs")
+	}
+
+	// Queue initial sync job; reconnecting while one is queued or running
+	// doesn't start another.
+	syncJob := services.GmailSyncJob.WithPriority(repository.PriorityInteractive)
+	if _, err := syncJob.EnqueueUnique(h.JobQueue, uid, "", services.GmailSyncPayload{}); err != nil {
 		h.Logger.WithError(err).Error("failed to queue initial sync")
 		// Don't fail the OAuth flow for this
 	}
@@ -131,7 +156,15 @@ func (h *GoogleHandler) Status(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	_, err = h.TokenRepo.Get(r.Context(), uid, providerGmail)
-	writeJSON(w, http.StatusOK, map[string]any{"connected": err == nil})
+	if err != nil {
+		writeJSON(w, http.StatusOK, map[string]any{"connected": false, "needs_reauth": false})
+		return
+	}
+	needsReauth, err := h.TokenRepo.NeedsReauth(r.Context(), uid, providerGmail)
+	if err != nil {
+		h.Logger.WithError(err).Warn("reading gmail reauth flag failed")
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"connected": true, "needs_reauth": needsReauth})
 }
 
 // POST /api/google/disconnect  (PROTECTED)
@@ -141,12 +174,98 @@ func (h *GoogleHandler) Disconnect(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "unauthorized", http.StatusUnauthorized)
 		return
 	}
-	if err := h.TokenRepo.Delete(r.Context(), uid, providerGmail); err != nil {
+	ctx := r.Context()
+
+	// imported=keep (default) | delete | anonymize
+	imported := r.URL.Query().Get("imported")
+	switch imported {
+	case "":
+		imported = "keep"
+	case "keep", "delete", "anonymize":
+	default:
+		http.Error(w, "imported must be keep, delete or anonymize", http.StatusBadRequest)
+		return
+	}
+
+	tok, err := h.TokenRepo.Get(ctx, uid, providerGmail)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		h.Logger.WithError(err).Error("loading gmail token failed")
+		http.Error(w, "failed to disconnect", http.StatusInternalServerError)
+		return
+	}
+	if tok != nil {
+		// Best effort: a dead grant can't stop its own watch, and the
+		// watch dies with the grant anyway.
+		client := h.OAuth.UserClient(ctx, h.TokenRepo, uid, providerGmail, tok)
+		if srv, err := gmail.NewService(ctx, option.WithHTTPClient(client)); err == nil {
+			if err := srv.Users.Stop("me").Do(); err != nil {
+				h.Logger.WithError(err).Info("stopping gmail watch failed")
+			}
+		}
+
+		revoke := tok.RefreshToken
+		if revoke == "" {
+			revoke = tok.AccessToken
+		}
+		if err := h.OAuth.Revoke(ctx, revoke); err != nil {
+			// Keep the token so the user can retry; we promised the grant goes away.
+			h.Logger.WithError(err).Error("revoking google grant failed")
+			http.Error(w, "failed to revoke google access", http.StatusBadGateway)
+			return
+		}
+	}
+
+	if err := h.TokenRepo.Delete(ctx, uid, providerGmail); err != nil {
 		h.Logger.WithError(err).Warn("disconnect gmail failed")
 		http.Error(w, "failed to disconnect", http.StatusInternalServerError)
 		return
 	}
-	writeJSON(w, http.StatusOK, map[string]string{"status": "disconnected"})
+	if err := h.SyncRepo.Delete(uid); err != nil {
+		h.Logger.WithError(err).Warn("deleting gmail sync status failed")
+	}
+	// Schedules first, so none fires a job after the queue is cleared.
+	if err := h.Schedules.DeleteUserSchedules(uid, "gmail_"); err != nil {
+		h.Logger.WithError(err).Warn("deleting gmail schedules failed")
+	}
+	if err := h.JobQueue.DeleteUserJobs(uid, "gmail_"); err != nil {
+		h.Logger.WithError(err).Warn("deleting pending gmail jobs failed")
+	}
+	// A sync already running would keep importing with the token it loaded.
+	if err := h.JobQueue.CancelRunningUserJobs(uid, "gmail_", "gmail disconnected"); err != nil {
+		h.Logger.WithError(err).Warn("cancelling running gmail jobs failed")
+	}
+	if err := h.MessageCache.Delete(uid); err != nil {
+		h.Logger.WithError(err).Warn("clearing gmail message cache failed")
+	}
+	if err := h.Classifier.Forget(uid); err != nil {
+		h.Logger.WithError(err).Warn("deleting classifier training data failed")
+	}
+	if err := h.Reclassifier.Discard(uid); err != nil {
+		h.Logger.WithError(err).Warn("deleting reclassify proposals failed")
+	}
+	if err := h.Filters.DeleteDismissed(uid); err != nil {
+		h.Logger.WithError(err).Warn("deleting dismissed messages failed")
+	}
+	h.Threads.Forget(uid)
+
+	var affected int64
+	switch imported {
+	case "delete":
+		affected, err = h.JobRepo.DeleteGmailImports(uid)
+	case "anonymize":
+		affected, err = h.JobRepo.AnonymizeGmailImports(uid)
+	}
+	if err != nil {
+		h.Logger.WithError(err).Error("purging gmail imports failed")
+		http.Error(w, "disconnected, but failed to "+imported+" imported jobs", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"status":        "disconnected",
+		"imported_jobs": imported,
+		"affected":      affected,
+	})
 }
 
 // GET /api/google/scan  (PROTECTED)
@@ -173,7 +292,7 @@ func (h *GoogleHandler) Scan(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	client := h.OAuth.Client(ctx, tok)
+	client := h.OAuth.UserClient(ctx, h.TokenRepo, uid, providerGmail, tok)
 	srv, err := gmail.NewService(ctx, option.WithHTTPClient(client))
 	if err != nil {
 		h.Logger.WithError(err).Error("gmail client init error")
@@ -203,8 +322,23 @@ func (h *GoogleHandler) Scan(w http.ResponseWriter, r *http.Request) {
 	cursor := r.URL.Query().Get("cursor")
 	only := r.URL.Query().Get("only")
 
+	profile, err := h.Profiles.Load(uid)
+	if err != nil {
+		h.Logger.WithError(err).Error("failed to load scan profile")
+		http.Error(w, "failed to load scan settings", http.StatusInternalServerError)
+		return
+	}
+
 	// 2. Call the scanner, passing the set of existing IDs.
-	res, err := h.Scanner.ScanPage(ctx, srv, since, until, limit, cursor, only, existingIDs)
+	res, err := h.Scanner.ScanPage(ctx, srv, services.NewMessageFetcher(client, srv), since, until, limit, cursor, only, existingIDs, profile)
+	if errors.Is(err, services.ErrGrantRevoked) {
+		h.Logger.WithError(err).Warn("gmail grant revoked")
+		http.Error(w, "gmail needs re-authorization", http.StatusUnauthorized)
+		return
+	}
+	if quotaExhausted(w, err) {
+		return
+	}
 	if err != nil {
 		h.Logger.WithError(err).Error("gmail scan failed")
 		http.Error(w, "scan failed", http.StatusInternalServerError)
@@ -223,6 +357,365 @@ func (h *GoogleHandler) Scan(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// GET /api/google/jobs/{id}/thread  (PROTECTED)
+// Returns the full Gmail thread(s) behind a job's linked messages.
+func (h *GoogleHandler) JobThread(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	uid, err := userIDFromContext(ctx)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	jobID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid job id", http.StatusBadRequest)
+		return
+	}
+
+	job, err := h.JobRepo.GetByID(jobID, uid)
+	if err != nil {
+		http.Error(w, "job not found", http.StatusNotFound)
+		return
+	}
+	emails, err := h.JobEmailRepo.GetByJobID(jobID, uid)
+	if err != nil {
+		h.Logger.WithError(err).Error("failed to load job emails")
+		http.Error(w, "failed to load job emails", http.StatusInternalServerError)
+		return
+	}
+
+	// Thread ids we already know, plus messages we still have to resolve.
+	var threadIDs, unresolved []string
+	seenThread := map[string]bool{}
+	seenMsg := map[string]bool{}
+	for _, e := range emails {
+		seenMsg[e.GmailMessageID] = true
+		if e.ThreadID == "" {
+			unresolved = append(unresolved, e.GmailMessageID)
+		} else if !seenThread[e.ThreadID] {
+			seenThread[e.ThreadID] = true
+			threadIDs = append(threadIDs, e.ThreadID)
+		}
+	}
+	if job.GmailMessageID != "" && !seenMsg[job.GmailMessageID] {
+		unresolved = append(unresolved, job.GmailMessageID)
+	}
+	if len(threadIDs) == 0 && len(unresolved) == 0 {
+		http.Error(w, "job has no linked gmail messages", http.StatusNotFound)
+		return
+	}
+
+	tok, err := h.TokenRepo.Get(ctx, uid, providerGmail)
+	if err != nil || tok == nil {
+		http.Error(w, "gmail not connected", http.StatusUnauthorized)
+		return
+	}
+	client := h.OAuth.UserClient(ctx, h.TokenRepo, uid, providerGmail, tok)
+	srv, err := gmail.NewService(ctx, option.WithHTTPClient(client))
+	if err != nil {
+		h.Logger.WithError(err).Error("gmail client init error")
+		http.Error(w, "gmail client error", http.StatusInternalServerError)
+		return
+	}
+
+	for _, msgID := range unresolved {
+		threadID, err := services.ThreadIDForMessage(ctx, srv, msgID)
+		if err != nil {
+			if h.gmailFailed(w, err) {
+				return
+			}
+			continue // message no longer in the mailbox
+		}
+		if !seenThread[threadID] {
+			seenThread[threadID] = true
+			threadIDs = append(threadIDs, threadID)
+		}
+	}
+
+	threads := make([]*services.EmailThread, 0, len(threadIDs))
+	for _, threadID := range threadIDs {
+		if t, ok := h.Threads.Get(uid, threadID); ok {
+			threads = append(threads, t)
+			continue
+		}
+		t, err := services.FetchThread(ctx, srv, threadID)
+		if err != nil {
+			if h.gmailFailed(w, err) {
+				return
+			}
+			continue
+		}
+		h.Threads.Set(uid, threadID, t)
+		threads = append(threads, t)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"job_id": jobID, "threads": threads})
+}
+
+// gmailFailed answers the request for Gmail errors that should abort it
+// and reports whether it did. Not-found errors are left to the caller.
+func (h *GoogleHandler) gmailFailed(w http.ResponseWriter, err error) bool {
+	var gerr *googleapi.Error
+	if errors.As(err, &gerr) && gerr.Code == http.StatusNotFound {
+		return false
+	}
+	if errors.Is(err, services.ErrGrantRevoked) {
+		http.Error(w, "gmail needs re-authorization", http.StatusUnauthorized)
+		return true
+	}
+	if quotaExhausted(w, err) {
+		return true
+	}
+	h.Logger.WithError(err).Error("gmail request failed")
+	http.Error(w, "gmail request failed", http.StatusBadGateway)
+	return true
+}
+
+// quotaExhausted answers 429 with a Retry-After when err is a spent daily
+// Gmail quota, and reports whether it did.
+func quotaExhausted(w http.ResponseWriter, err error) bool {
+	var qerr *services.QuotaExhaustedError
+	if !errors.As(err, &qerr) {
+		return false
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int(time.Until(qerr.RetryAt).Seconds())+1))
+	http.Error(w, "gmail quota exhausted for today", http.StatusTooManyRequests)
+	return true
+}
+
+// GET /api/google/classifier  (PROTECTED)
+// How much the user's learned classifier knows and how well it does.
+func (h *GoogleHandler) ClassifierInfo(w http.ResponseWriter, r *http.Request) {
+	uid, err := userIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	info, err := h.Classifier.Info(uid)
+	if err != nil {
+		h.Logger.WithError(err).Error("failed to load classifier info")
+		http.Error(w, "failed to load classifier info", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, info)
+}
+
+// GET /api/google/sender-rules  (PROTECTED)
+func (h *GoogleHandler) ListSenderRules(w http.ResponseWriter, r *http.Request) {
+	uid, err := userIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	rules, err := h.Filters.ListRules(uid)
+	if err != nil {
+		h.Logger.WithError(err).Error("failed to list sender rules")
+		http.Error(w, "failed to list sender rules", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"rules": rules})
+}
+
+// POST /api/google/sender-rules  (PROTECTED)
+// Body: {"pattern": "acme.com" | "jobs@acme.com", "kind": "allow" | "block"}
+func (h *GoogleHandler) SaveSenderRule(w http.ResponseWriter, r *http.Request) {
+	uid, err := userIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var req models.SenderRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Kind != "allow" && req.Kind != "block" {
+		http.Error(w, "kind must be allow or block", http.StatusBadRequest)
+		return
+	}
+	if err := services.ValidSenderPattern(req.Pattern); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rule := &models.SenderRule{UserID: uid, Pattern: req.Pattern, Kind: req.Kind}
+	if err := h.Filters.SaveRule(rule); err != nil {
+		h.Logger.WithError(err).Error("failed to save sender rule")
+		http.Error(w, "failed to save sender rule", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusCreated, rule)
+}
+
+// DELETE /api/google/sender-rules/{id}  (PROTECTED)
+func (h *GoogleHandler) DeleteSenderRule(w http.ResponseWriter, r *http.Request) {
+	uid, err := userIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid rule id", http.StatusBadRequest)
+		return
+	}
+	if err := h.Filters.DeleteRule(id, uid); err != nil {
+		http.Error(w, "sender rule not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+// GET /api/google/languages  (PROTECTED)
+// Lists the phrase packs scans can use and which ones the user picked.
+func (h *GoogleHandler) ListLanguages(w http.ResponseWriter, r *http.Request) {
+	uid, err := userIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	langs, err := h.Filters.Languages(uid)
+	if err != nil {
+		h.Logger.WithError(err).Error("failed to load scan languages")
+		http.Error(w, "failed to load scan languages", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"available": services.PhrasePacks(),
+		"selected":  services.NewLanguages(langs),
+	})
+}
+
+// PUT /api/google/languages  (PROTECTED)
+// Body: {"languages": ["de", "fr"]}; English is always included.
+func (h *GoogleHandler) SaveLanguages(w http.ResponseWriter, r *http.Request) {
+	uid, err := userIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var req struct {
+		Languages []string `json:"languages"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	for _, l := range req.Languages {
+		if !services.ValidLanguage(l) {
+			http.Error(w, "unknown language: "+l, http.StatusBadRequest)
+			return
+		}
+	}
+
+	langs := services.NewLanguages(req.Languages)
+	if err := h.Filters.SaveLanguages(uid, langs); err != nil {
+		h.Logger.WithError(err).Error("failed to save scan languages")
+		http.Error(w, "failed to save scan languages", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"selected": langs})
+}
+
+// POST /api/google/reclassify  (PROTECTED)
+// Queues a re-run of the current extractors over past Gmail imports.
+func (h *GoogleHandler) StartReclassify(w http.ResponseWriter, r *http.Request) {
+	uid, err := userIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if _, err := h.TokenRepo.Get(r.Context(), uid, "gmail"); err != nil {
+		http.Error(w, "gmail not connected", http.StatusBadRequest)
+		return
+	}
+	if err := h.Reclassifier.Queue(uid); err != nil {
+		h.Logger.WithError(err).Error("failed to queue reclassification")
+		http.Error(w, "failed to queue reclassification", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]string{"status": "queued"})
+}
+
+// GET /api/google/reclassify  (PROTECTED)
+// Returns the proposed changes from the last run, and whether a run is
+// still waiting.
+func (h *GoogleHandler) ReclassifyProposals(w http.ResponseWriter, r *http.Request) {
+	uid, err := userIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	pending, err := h.Reclassifier.Pending(uid)
+	if err != nil {
+		h.Logger.WithError(err).Error("failed to check reclassification")
+		http.Error(w, "failed to load proposals", http.StatusInternalServerError)
+		return
+	}
+	proposals, err := h.Reclassifier.Proposals(uid)
+	if err != nil {
+		h.Logger.WithError(err).Error("failed to list proposals")
+		http.Error(w, "failed to load proposals", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"pending": pending, "proposals": proposals})
+}
+
+// POST /api/google/reclassify/apply  (PROTECTED)
+// Body: {"ids": [1, 2]} or {"all": true}
+func (h *GoogleHandler) ApplyReclassify(w http.ResponseWriter, r *http.Request) {
+	uid, err := userIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var req models.ApplyProposalsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	applied, err := h.Reclassifier.Apply(uid, &req)
+	if err != nil {
+		h.Logger.WithError(err).Error("failed to apply proposals")
+		http.Error(w, "failed to apply proposals", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]int{"applied": applied})
+}
+
+// DELETE /api/google/reclassify  (PROTECTED)
+func (h *GoogleHandler) DiscardReclassify(w http.ResponseWriter, r *http.Request) {
+	uid, err := userIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if err := h.Reclassifier.Discard(uid); err != nil {
+		h.Logger.WithError(err).Error("failed to discard proposals")
+		http.Error(w, "failed to discard proposals", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "discarded"})
+}
+
+// GET /api/google/quota  (PROTECTED)
+// The user's Gmail API quota use today and over the last week.
+func (h *GoogleHandler) Quota(w http.ResponseWriter, r *http.Request) {
+	uid, err := userIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	st, err := h.OAuth.Quota.Status(uid)
+	if err != nil {
+		h.Logger.WithError(err).Error("failed to load quota usage")
+		http.Error(w, "failed to load quota usage", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, st)
+}
+
 func (h *GoogleHandler) SyncStatus(w http.ResponseWriter, r *http.Request) {
 	userID, err := userIDFromContext(r.Context())
 	if err != nil {
@@ -248,6 +741,12 @@ func (h *GoogleHandler) SyncStatus(w http.ResponseWriter, r *http.Request) {
 	// Your logic to determine if syncing is in progress.
 	// For example: is it started but not completed?
 	isSyncingNow := status.InitialSyncStartedAt != nil && !status.InitialSyncCompleted
+	if isSyncingNow {
+		// A sync whose job failed for good is not in progress any more.
+		if active, err := h.JobQueue.HasActiveJob(services.GmailSyncJob.Name, userID); err == nil {
+			isSyncingNow = active
+		}
+	}
 
 	// The `found_count` will come from another table later,
 	// where you store the emails found but not yet imported.
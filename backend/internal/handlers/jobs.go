@@ -89,6 +89,44 @@ func (h *JobHandler) GetJobs(w http.ResponseWriter, r *http.Request) {
 	h.respondJSON(w, response, http.StatusOK)
 }
 
+func (h *JobHandler) GetJobEmails(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(int)
+	vars := mux.Vars(r)
+
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		h.respondError(w, "Invalid job ID", http.StatusBadRequest)
+		return
+	}
+
+	emails, err := h.jobService.GetJobEmails(id, userID)
+	if err != nil {
+		h.respondError(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	h.respondJSON(w, map[string]interface{}{"emails": emails}, http.StatusOK)
+}
+
+func (h *JobHandler) GetJobContacts(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(int)
+	vars := mux.Vars(r)
+
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		h.respondError(w, "Invalid job ID", http.StatusBadRequest)
+		return
+	}
+
+	contacts, err := h.jobService.GetJobContacts(id, userID)
+	if err != nil {
+		h.respondError(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	h.respondJSON(w, map[string]interface{}{"contacts": contacts}, http.StatusOK)
+}
+
 func (h *JobHandler) UpdateJob(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value("user_id").(int)
 	vars := mux.Vars(r)
@@ -134,6 +172,36 @@ func (h *JobHandler) DeleteJob(w http.ResponseWriter, r *http.Request) {
 	h.respondJSON(w, map[string]string{"message": "Job deleted successfully"}, http.StatusOK)
 }
 
+// NotAJob deletes a Gmail-imported job the user says isn't about a job and
+// keeps future scans from bringing it back.
+func (h *JobHandler) NotAJob(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(int)
+	vars := mux.Vars(r)
+
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		h.respondError(w, "Invalid job ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.NotAJobRequest
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			h.respondError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	result, err := h.jobService.NotAJob(id, userID, &req)
+	if err != nil {
+		h.logger.Error("Failed to dismiss job:", err)
+		h.respondError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.respondJSON(w, result, http.StatusOK)
+}
+
 func (h *JobHandler) respondJSON(w http.ResponseWriter, data interface{}, status int) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gant123/jobTracker/internal/jobs"
+	"github.com/gant123/jobTracker/internal/models"
+	"github.com/gant123/jobTracker/internal/repository"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// ScheduleHandler lets users run background jobs on a schedule of their
+// own, e.g. a Gmail sync every six hours.
+type ScheduleHandler struct {
+	scheduler *jobs.Scheduler
+	registry  *jobs.Registry
+	schedules *repository.ScheduleRepository
+	logger    *logrus.Logger
+}
+
+func NewScheduleHandler(scheduler *jobs.Scheduler, registry *jobs.Registry, schedules *repository.ScheduleRepository, logger *logrus.Logger) *ScheduleHandler {
+	return &ScheduleHandler{scheduler: scheduler, registry: registry, schedules: schedules, logger: logger}
+}
+
+// GET /api/schedules  (PROTECTED)
+// The user's schedules and the job types they can schedule.
+func (h *ScheduleHandler) List(w http.ResponseWriter, r *http.Request) {
+	uid, err := userIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	schedules, err := h.schedules.GetByUserID(uid)
+	if err != nil {
+		h.logger.WithError(err).Error("failed to list schedules")
+		http.Error(w, "failed to list schedules", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"schedules": schedules,
+		"job_types": h.registry.Schedulable(),
+	})
+}
+
+// POST /api/schedules  (PROTECTED)
+// Schedules a job type, e.g. {"job_type": "gmail_initial_sync",
+// "payload": {"days": 2}, "cron": "0 */6 * * *", "timezone": "Europe/Berlin"}.
+func (h *ScheduleHandler) Create(w http.ResponseWriter, r *http.Request) {
+	uid, err := userIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var req models.JobScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	sched, err := h.scheduler.AddUserSchedule(uid, &req)
+	if errors.Is(err, jobs.ErrInvalidSchedule) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		h.logger.WithError(err).Error("failed to save schedule")
+		http.Error(w, "failed to save schedule", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusCreated, sched)
+}
+
+// DELETE /api/schedules/{id}  (PROTECTED)
+func (h *ScheduleHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	uid, err := userIDFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid schedule id", http.StatusBadRequest)
+		return
+	}
+	if err := h.schedules.Delete(id, uid); err != nil {
+		if errors.Is(err, repository.ErrScheduleNotFound) {
+			http.Error(w, "schedule not found", http.StatusNotFound)
+			return
+		}
+		h.logger.WithError(err).Error("failed to delete schedule")
+		http.Error(w, "failed to delete schedule", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
@@ -0,0 +1,160 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronErrors(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"* * * foo *",
+		"@often",
+	} {
+		if _, err := ParseCron(spec); err == nil {
+			t.Errorf("ParseCron(%q) succeeded, want an error", spec)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	utc := func(s string) time.Time {
+		tm, err := time.Parse("2006-01-02 15:04", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tm
+	}
+	// 2024-01-15 is a Monday.
+	from := utc("2024-01-15 10:07")
+	tests := []struct {
+		spec string
+		from time.Time
+		want string
+	}{
+		{"* * * * *", from, "2024-01-15 10:08"},
+		{"*/15 * * * *", from, "2024-01-15 10:15"},
+		{"0 * * * *", from, "2024-01-15 11:00"},
+		{"@hourly", from, "2024-01-15 11:00"},
+		{"@daily", from, "2024-01-16 00:00"},
+		{"@midnight", from, "2024-01-16 00:00"},
+		{"0 3 * * *", from, "2024-01-16 03:00"},
+		{"30 9-17/2 * * *", from, "2024-01-15 11:30"},
+		{"0 9 * * 1-5", utc("2024-01-19 10:00"), "2024-01-22 09:00"},
+		{"0 9 * * mon,fri", from, "2024-01-19 09:00"},
+		{"0 0 * * 0", from, "2024-01-21 00:00"},
+		{"0 0 * * 7", from, "2024-01-21 00:00"},
+		{"0 0 * * sun", from, "2024-01-21 00:00"},
+		{"@weekly", from, "2024-01-21 00:00"},
+		{"0 0 1 * *", from, "2024-02-01 00:00"},
+		{"@monthly", from, "2024-02-01 00:00"},
+		{"0 0 1 jan *", from, "2025-01-01 00:00"},
+		{"@yearly", from, "2025-01-01 00:00"},
+		{"0 0 29 feb *", from, "2024-02-29 00:00"},
+		{"0 0 31 * *", utc("2024-04-01 00:00"), "2024-05-31 00:00"},
+		// Both day fields restricted: either one matches.
+		{"0 0 13 * fri", from, "2024-01-19 00:00"},
+		{"0 0 16 * sun", from, "2024-01-16 00:00"},
+	}
+	for _, tt := range tests {
+		c, err := ParseCron(tt.spec)
+		if err != nil {
+			t.Errorf("ParseCron(%q): %v", tt.spec, err)
+			continue
+		}
+		if got := c.Next(tt.from); !got.Equal(utc(tt.want)) {
+			t.Errorf("%q.Next(%s) = %s, want %s", tt.spec, tt.from.Format("2006-01-02 15:04"),
+				got.Format("2006-01-02 15:04"), tt.want)
+		}
+	}
+}
+
+func TestCronNextNever(t *testing.T) {
+	c, err := ParseCron("0 0 30 2 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Next(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)); !got.IsZero() {
+		t.Errorf("Next = %s, want the zero time", got)
+	}
+}
+
+func TestCronNextInLocation(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("no tz database:", err)
+	}
+	c, err := ParseCron("0 9 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := c.Next(time.Date(2024, 1, 15, 10, 0, 0, 0, ny))
+	if want := time.Date(2024, 1, 16, 9, 0, 0, 0, ny); !got.Equal(want) {
+		t.Errorf("Next = %s, want %s", got, want)
+	}
+
+	// 2:30 doesn't exist on 2024-03-10; that day is skipped.
+	c, err = ParseCron("30 2 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = c.Next(time.Date(2024, 3, 9, 12, 0, 0, 0, ny))
+	if want := time.Date(2024, 3, 11, 2, 30, 0, 0, ny); !got.Equal(want) {
+		t.Errorf("Next across DST = %s, want %s", got, want)
+	}
+
+	// 1:30 happens twice on 2024-11-03; it runs the first time only.
+	c, err = ParseCron("30 1 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := c.Next(time.Date(2024, 11, 3, 0, 0, 0, 0, ny))
+	if want := time.Date(2024, 11, 3, 1, 30, 0, 0, ny); !first.Equal(want) {
+		t.Errorf("Next before fall back = %s, want %s", first, want)
+	}
+	got = c.Next(first)
+	if want := time.Date(2024, 11, 4, 1, 30, 0, 0, ny); !got.Equal(want) {
+		t.Errorf("Next after fall back = %s, want %s", got, want)
+	}
+
+	// Hourly runs keep going through both changes.
+	c, err = ParseCron("0 * * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = c.Next(time.Date(2024, 3, 10, 1, 30, 0, 0, ny))
+	if want := time.Date(2024, 3, 10, 3, 0, 0, 0, ny); !got.Equal(want) {
+		t.Errorf("hourly Next into the gap = %s, want %s", got, want)
+	}
+}
+
+func TestCronMinInterval(t *testing.T) {
+	from := time.Date(2024, 1, 15, 10, 7, 0, 0, time.UTC)
+	tests := []struct {
+		spec string
+		want time.Duration
+	}{
+		{"*/5 * * * *", 5 * time.Minute},
+		{"0 */6 * * *", 6 * time.Hour},
+		{"0 9,10 * * *", time.Hour},
+		{"@daily", 24 * time.Hour},
+	}
+	for _, tt := range tests {
+		c, err := ParseCron(tt.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := c.MinInterval(from); got != tt.want {
+			t.Errorf("%q.MinInterval = %s, want %s", tt.spec, got, tt.want)
+		}
+	}
+}
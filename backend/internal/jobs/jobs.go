@@ -0,0 +1,184 @@
+// Package jobs runs background work queued in the background_jobs table.
+//
+// Each job type declares its payload as a Go type and registers one
+// handler for it:
+//
+//	var Sync = jobs.Type[SyncPayload]{Name: "gmail_initial_sync"}
+//
+//	jobs.Register(registry, Sync, func(ctx context.Context, log *logrus.Entry, job *jobs.Job, p SyncPayload) error {
+//		...
+//	})
+//	Sync.Enqueue(queue, userID, SyncPayload{IncludeSent: true})
+//	Sync.EnqueueUnique(queue, userID, "", SyncPayload{}) // unless one is queued
+//	Sync.WithPriority(repository.PriorityInteractive).Enqueue(queue, userID, SyncPayload{})
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/gant123/jobTracker/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// Type names a job type and fixes the type of its payload.
+type Type[P any] struct {
+	Name string
+	// Retry overrides repository.DefaultRetryPolicy once the type is
+	// registered.
+	Retry *repository.RetryPolicy
+	// Schedulable lets users put the type on a schedule of their own.
+	Schedulable bool
+	// Priority is the class jobs of this type are queued in, background
+	// unless set.
+	Priority repository.Priority
+}
+
+// WithPriority returns t queueing in class p, for e.g. a job a user is
+// waiting on.
+func (t Type[P]) WithPriority(p repository.Priority) Type[P] {
+	t.Priority = p
+	return t
+}
+
+// None is the payload of jobs that need nothing beyond their user.
+type None struct{}
+
+// Enqueue queues a job of this type for userID.
+func (t Type[P]) Enqueue(q *repository.JobQueueRepository, userID int, payload P) error {
+	return q.CreateJob(t.Name, userID, t.Priority, payload)
+}
+
+// EnqueueUnique queues a job of this type for userID unless one with the
+// same key is already waiting or running, and reports whether it did. Use
+// "" for at most one per type and user. A job already queued is moved up
+// to t's priority if that's higher.
+func (t Type[P]) EnqueueUnique(q *repository.JobQueueRepository, userID int, key string, payload P) (bool, error) {
+	err := q.CreateUniqueJob(t.Name, userID, t.Priority, key, payload)
+	if errors.Is(err, repository.ErrDuplicate) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Job is what a handler knows about the job it runs.
+type Job struct {
+	ID       int
+	Type     string
+	UserID   int // 0 for system jobs
+	Attempts int // including this one
+}
+
+// HandlerFunc runs one job with its decoded payload. A nil error completes
+// the job; other errors are retried per the type's policy unless wrapped
+// with RetryAt, Pause or Permanent. Jobs out of retries are kept as dead
+// until replayed.
+type HandlerFunc[P any] func(ctx context.Context, log *logrus.Entry, job *Job, payload P) error
+
+type handler func(ctx context.Context, log *logrus.Entry, job *Job, payload json.RawMessage) error
+
+// Registry maps job types to their handlers.
+type Registry struct {
+	queue    *repository.JobQueueRepository
+	handlers map[string]handler
+	// Payload checks of the types users may schedule
+	schedulable map[string]func(json.RawMessage) error
+	priorities  map[string]repository.Priority
+}
+
+func NewRegistry(queue *repository.JobQueueRepository) *Registry {
+	return &Registry{
+		queue:       queue,
+		handlers:    map[string]handler{},
+		schedulable: map[string]func(json.RawMessage) error{},
+		priorities:  map[string]repository.Priority{},
+	}
+}
+
+// Schedulable lists the job types users may schedule.
+func (r *Registry) Schedulable() []string {
+	names := make([]string, 0, len(r.schedulable))
+	for name := range r.schedulable {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Register installs h as the handler of t. Registering a type twice is a
+// programming error.
+func Register[P any](r *Registry, t Type[P], h HandlerFunc[P]) {
+	if _, dup := r.handlers[t.Name]; dup {
+		panic("jobs: handler for " + t.Name + " registered twice")
+	}
+	if t.Retry != nil {
+		r.queue.SetRetryPolicy(t.Name, *t.Retry)
+	}
+	r.priorities[t.Name] = t.Priority
+	if t.Schedulable {
+		r.schedulable[t.Name] = func(raw json.RawMessage) error {
+			var p P
+			return json.Unmarshal(raw, &p)
+		}
+	}
+	r.handlers[t.Name] = func(ctx context.Context, log *logrus.Entry, job *Job, raw json.RawMessage) error {
+		var p P
+		if len(raw) > 0 {
+			if err := json.Unmarshal(raw, &p); err != nil {
+				return Permanent(fmt.Errorf("invalid %s payload: %w", t.Name, err))
+			}
+		}
+		return h(ctx, log, job, p)
+	}
+}
+
+// ---------- Outcomes ----------
+
+type retryAtError struct {
+	at  time.Time
+	err error
+}
+
+func (e *retryAtError) Error() string { return e.err.Error() }
+func (e *retryAtError) Unwrap() error { return e.err }
+
+// RetryAt puts the job back in the queue to run at a later time without
+// spending an attempt, for when nothing is wrong with the job itself
+// (e.g. a quota budget is spent).
+func RetryAt(at time.Time, err error) error {
+	return &retryAtError{at: at, err: err}
+}
+
+type pauseError struct {
+	typePrefix string
+	err        error
+}
+
+func (e *pauseError) Error() string { return e.err.Error() }
+func (e *pauseError) Unwrap() error { return e.err }
+
+// Pause parks the job until the user acts (e.g. reconnects Gmail). With a
+// typePrefix, the user's pending jobs of those types are parked too.
+func Pause(err error, typePrefix string) error {
+	return &pauseError{typePrefix: typePrefix, err: err}
+}
+
+type permanentError struct{ err error }
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent sends the job straight to the dead-letter state; retrying
+// can't help.
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+func isPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p)
+}
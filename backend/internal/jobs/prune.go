@@ -0,0 +1,72 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/gant123/jobTracker/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// PruneJob deletes what the queue is done with once it is past its
+// retention. Put it on a System schedule; the retention travels in the
+// payload, so a config change applies from the next start.
+var PruneJob = Type[Retention]{Name: "jobs_prune", Priority: repository.PriorityMaintenance}
+
+// Retention is how long the queue keeps finished work. Zero keeps it
+// forever.
+type Retention struct {
+	// Completed and cancelled jobs, counted from when they finished.
+	Jobs time.Duration `json:"jobs"`
+	// Attempt logs, and dead jobs that nobody replayed.
+	Logs time.Duration `json:"logs"`
+}
+
+// Rows per delete, so a large backlog is pruned without long locks.
+const pruneBatch = 1000
+
+// Prune is the handler of PruneJob.
+func (w *Worker) Prune(ctx context.Context, log *logrus.Entry, job *Job, r Retention) error {
+	var jobs, dead, attempts int64
+	var err error
+	if r.Jobs > 0 {
+		jobs, err = pruneAll(ctx, func() (int64, error) {
+			return w.queue.PruneJobs(r.Jobs, pruneBatch, "completed", "cancelled")
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if r.Logs > 0 {
+		dead, err = pruneAll(ctx, func() (int64, error) {
+			return w.queue.PruneJobs(r.Logs, pruneBatch, "dead")
+		})
+		if err != nil {
+			return err
+		}
+		attempts, err = pruneAll(ctx, func() (int64, error) {
+			return w.queue.PruneAttempts(r.Logs, pruneBatch)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	log.WithFields(logrus.Fields{"jobs": jobs, "dead": dead, "attempts": attempts}).Info("Pruned background jobs")
+	return nil
+}
+
+// pruneAll runs del until it deletes less than a full batch.
+func pruneAll(ctx context.Context, del func() (int64, error)) (int64, error) {
+	var total int64
+	for {
+		n, err := del()
+		total += n
+		if err != nil || n < pruneBatch {
+			return total, err
+		}
+		Progress(ctx)
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gant123/jobTracker/internal/models"
+	"github.com/gant123/jobTracker/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// How often the scheduler looks for due schedules. Crons have minute
+	// resolution, so runs start up to this late.
+	scheduleTick = 30 * time.Second
+	// Users can't schedule a job more often than this.
+	MinUserInterval = time.Hour
+	// Schedules one user may keep.
+	MaxUserSchedules = 10
+)
+
+// ErrInvalidSchedule is returned for schedules that can't be kept: an
+// unknown or unschedulable type, a bad cron or timezone, or too many runs.
+var ErrInvalidSchedule = errors.New("invalid schedule")
+
+// Scheduler queues jobs as their cron schedules come due. Every instance
+// runs one; the database lets only one of them fire at a time.
+type Scheduler struct {
+	schedules *repository.ScheduleRepository
+	queue     *repository.JobQueueRepository
+	registry  *Registry
+	logger    *logrus.Logger
+}
+
+func NewScheduler(schedules *repository.ScheduleRepository, queue *repository.JobQueueRepository, registry *Registry, logger *logrus.Logger) *Scheduler {
+	return &Scheduler{schedules: schedules, queue: queue, registry: registry, logger: logger}
+}
+
+// System keeps t on a schedule that belongs to no user, read in UTC. It is
+// called at startup; the last call for a type wins.
+func System[P any](s *Scheduler, t Type[P], spec string, payload P) error {
+	c, err := ParseCron(spec)
+	if err != nil {
+		return err
+	}
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return s.schedules.SaveSystem(&models.JobSchedule{
+		JobType:   t.Name,
+		Payload:   raw,
+		Cron:      spec,
+		Timezone:  "UTC",
+		NextRunAt: c.Next(time.Now().UTC()),
+	})
+}
+
+// AddUserSchedule checks and saves a schedule of the user's own. Errors
+// wrapping ErrInvalidSchedule are the user's to fix.
+func (s *Scheduler) AddUserSchedule(userID int, req *models.JobScheduleRequest) (*models.JobSchedule, error) {
+	check, ok := s.registry.schedulable[req.JobType]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q can't be scheduled", ErrInvalidSchedule, req.JobType)
+	}
+	payload := req.Payload
+	if len(payload) == 0 {
+		payload = json.RawMessage("{}")
+	}
+	if err := check(payload); err != nil {
+		return nil, fmt.Errorf("%w: bad payload: %v", ErrInvalidSchedule, err)
+	}
+	if req.Timezone == "" {
+		req.Timezone = "UTC"
+	}
+	loc, err := time.LoadLocation(req.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unknown timezone %q", ErrInvalidSchedule, req.Timezone)
+	}
+	c, err := ParseCron(req.Cron)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSchedule, err)
+	}
+	now := time.Now().In(loc)
+	next := c.Next(now)
+	if next.IsZero() {
+		return nil, fmt.Errorf("%w: %q never runs", ErrInvalidSchedule, req.Cron)
+	}
+	if c.MinInterval(now) < MinUserInterval {
+		return nil, fmt.Errorf("%w: runs more than once every %s", ErrInvalidSchedule, MinUserInterval)
+	}
+
+	sched := &models.JobSchedule{
+		UserID:    &userID,
+		JobType:   req.JobType,
+		Payload:   payload,
+		Cron:      req.Cron,
+		Timezone:  req.Timezone,
+		NextRunAt: next,
+	}
+	err = s.schedules.Create(sched, MaxUserSchedules)
+	if errors.Is(err, repository.ErrScheduleLimit) {
+		return nil, fmt.Errorf("%w: at most %d schedules", ErrInvalidSchedule, MaxUserSchedules)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return sched, nil
+}
+
+// Run fires due schedules until ctx is cancelled.
+func (s *Scheduler) Run(ctx context.Context) {
+	t := time.NewTicker(scheduleTick)
+	defer t.Stop()
+	for {
+		s.fire()
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (s *Scheduler) fire() {
+	fired, _, err := s.schedules.FireDue(time.Now(), s.plan)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to fire job schedules")
+		return
+	}
+	if fired > 0 {
+		s.logger.WithField("jobs", fired).Info("Queued scheduled jobs")
+	}
+}
+
+// plan works out when a due schedule runs next, counting from now so runs
+// missed while no instance was up collapse into the one being fired.
+func (s *Scheduler) plan(sched *models.JobSchedule) (time.Time, repository.ScheduledJob) {
+	job := repository.ScheduledJob{
+		MaxAttempts: s.queue.RetryPolicy(sched.JobType).MaxAttempts,
+		Priority:    s.registry.priorities[sched.JobType],
+	}
+	c, err := ParseCron(sched.Cron)
+	if err != nil {
+		s.logger.WithError(err).WithField("schedule_id", sched.ID).Error("Disabling schedule with a bad cron")
+		return time.Time{}, job
+	}
+	loc, err := time.LoadLocation(sched.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	return c.Next(time.Now().In(loc)), job
+}
@@ -0,0 +1,370 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gant123/jobTracker/internal/repository"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// A running job whose heartbeat is older than this is presumed
+	// orphaned by a dead worker and reaped.
+	LeaseTTL = 2 * time.Minute
+	// Heartbeats stop once a job has reported no progress for this long,
+	// so a hung job is treated like a crashed one.
+	StallTimeout = 15 * time.Minute
+	// Idle workers wake when a job is queued (see Listen). Polling only
+	// catches what a dropped LISTEN connection missed.
+	PollInterval = time.Minute
+)
+
+// Worker claims jobs from the queue and runs their registered handlers.
+type Worker struct {
+	id       string
+	queue    *repository.JobQueueRepository
+	registry *Registry
+	logger   *logrus.Logger
+	connStr  string        // LISTEN connection, if any
+	wake     chan struct{} // one token per idle worker to wake
+}
+
+func NewWorker(queue *repository.JobQueueRepository, registry *Registry, logger *logrus.Logger) *Worker {
+	return &Worker{id: workerID(), queue: queue, registry: registry, logger: logger}
+}
+
+// Listen has the worker wake up for jobs as soon as they are queued,
+// listening on a connection of its own to connStr. Without it jobs wait
+// for the next poll.
+func (w *Worker) Listen(connStr string) {
+	w.connStr = connStr
+}
+
+// workerID names this process in background_jobs.worker_id.
+func workerID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "worker"
+	}
+	return fmt.Sprintf("%s-%d-%04x", host, os.Getpid(), rand.Intn(1<<16))
+}
+
+// Run processes jobs on size goroutines until ctx is cancelled. Each one
+// drains the queue back to back and only sleeps when it comes up empty,
+// until a job is queued or a delayed one falls due. Once ctx is done no
+// new jobs are claimed; running ones get grace to finish, after which they
+// are interrupted and put back in the queue to resume from what they
+// already did.
+func (w *Worker) Run(ctx context.Context, size int, grace time.Duration) {
+	w.logger.WithFields(logrus.Fields{"worker_id": w.id, "size": size}).Info("Starting background worker")
+
+	// Jobs don't inherit ctx: SIGTERM stops the claiming, not the work.
+	jobCtx, cancelJobs := context.WithCancel(context.Background())
+	defer cancelJobs()
+
+	w.wake = make(chan struct{}, size)
+	var wg sync.WaitGroup
+	if w.connStr != "" {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.listen(ctx)
+		}()
+	}
+	for i := 0; i < size; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.drain(ctx, jobCtx)
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		w.reap(ctx)
+	}()
+
+	<-ctx.Done()
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(grace):
+		w.logger.Warn("Shutdown grace period over, interrupting running jobs")
+		cancelJobs()
+		<-done
+	}
+	w.logger.Info("Background worker stopped")
+}
+
+func (w *Worker) drain(ctx, jobCtx context.Context) {
+	for {
+		for ctx.Err() == nil && w.processNext(jobCtx) {
+		}
+		timer := time.NewTimer(w.idleWait())
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-w.wake:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// idleWait is how long a worker with nothing to do sleeps: until the next
+// delayed job is due (nothing notifies for those), at most PollInterval.
+func (w *Worker) idleWait() time.Duration {
+	d, ok, err := w.queue.NextDueIn()
+	if err != nil || !ok || d > PollInterval {
+		return PollInterval
+	}
+	return max(d, time.Second)
+}
+
+// listen turns notifications on repository.JobsChannel into wake-ups.
+func (w *Worker) listen(ctx context.Context) {
+	l := pq.NewListener(w.connStr, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		switch ev {
+		case pq.ListenerEventDisconnected, pq.ListenerEventConnectionAttemptFailed:
+			w.logger.WithError(err).Warn("Job listener lost its connection, polling until it's back")
+		case pq.ListenerEventReconnected:
+			w.logger.Info("Job listener reconnected")
+		}
+	})
+	defer l.Close()
+	// Close also unblocks Listen while the database is unreachable.
+	stop := context.AfterFunc(ctx, func() { l.Close() })
+	defer stop()
+
+	if err := l.Listen(repository.JobsChannel); err != nil {
+		if ctx.Err() == nil {
+			w.logger.WithError(err).Error("Failed to listen for jobs, polling instead")
+		}
+		return
+	}
+
+	ping := time.NewTicker(90 * time.Second)
+	defer ping.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case n, ok := <-l.Notify:
+			if !ok {
+				return
+			}
+			if n == nil {
+				// Reconnected; anything sent meanwhile was missed.
+				for i := 0; i < cap(w.wake); i++ {
+					w.wakeOne()
+				}
+				continue
+			}
+			w.wakeOne()
+		case <-ping.C:
+			// Notices a dead connection sooner than TCP would.
+			go l.Ping()
+		}
+	}
+}
+
+// wakeOne wakes an idle worker, if there is room for another one.
+func (w *Worker) wakeOne() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (w *Worker) reap(ctx context.Context) {
+	t := time.NewTicker(LeaseTTL / 2)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			w.reapExpired()
+		}
+	}
+}
+
+// reapExpired returns jobs orphaned by a crashed worker to the queue.
+// Every instance reaps; the update is atomic, so that's harmless.
+func (w *Worker) reapExpired() {
+	n, err := w.queue.ReapExpiredJobs(LeaseTTL)
+	if err != nil {
+		w.logger.WithError(err).Error("Failed to reap expired jobs")
+		return
+	}
+	if n > 0 {
+		w.logger.WithField("jobs", n).Warn("Reaped jobs whose worker stopped heartbeating")
+	}
+}
+
+// processNext runs one job under base and reports whether there was one
+// to run.
+func (w *Worker) processNext(base context.Context) bool {
+	bj, err := w.queue.GetNextJob(w.id)
+	if err == sql.ErrNoRows {
+		return false // No jobs
+	}
+	if err != nil {
+		w.logger.WithError(err).Error("Failed to get next job")
+		return false
+	}
+
+	log := w.logger.WithFields(logrus.Fields{
+		"job_id":   bj.ID,
+		"type":     bj.Type,
+		"user_id":  bj.UserID,
+		"attempts": bj.Attempts,
+	})
+	h, ok := w.registry.handlers[bj.Type]
+	if !ok {
+		// Leaving it in processing would only have the reaper retry it
+		// until its attempts ran out.
+		log.Error("Unknown job type")
+		msg := "unknown job type " + bj.Type
+		w.queue.MarkJobDead(bj.ID, msg)
+		w.finishAttempt(log, bj, "failed", msg)
+		return true
+	}
+	log.Info("Processing job")
+
+	ctx, cancel := context.WithCancel(base)
+	defer cancel()
+	l := &lease{queue: w.queue, job: bj, cancel: cancel}
+	l.progress.Store(time.Now().UnixNano())
+	ctx = context.WithValue(ctx, leaseKey{}, l)
+	go l.keepAlive(ctx, log)
+
+	job := &Job{ID: bj.ID, Type: bj.Type, UserID: bj.UserID, Attempts: bj.Attempts}
+	err = h(ctx, log, job, bj.Payload)
+
+	if l.lost.Load() {
+		// The reaper owns the row now; whatever we record would race it. It
+		// logs the attempt as lost.
+		log.WithError(err).Warn("Abandoning job without recording its outcome")
+		return true
+	}
+	if base.Err() != nil {
+		// Interrupted by shutdown; nothing wrong with the job itself.
+		log.Info("Job interrupted by shutdown, requeueing")
+		w.queue.RescheduleJob(bj.ID, time.Now(), "interrupted by shutdown")
+		w.finishAttempt(log, bj, "interrupted", "")
+		return true
+	}
+	w.record(log, bj, err)
+	return true
+}
+
+// record stores the outcome of a finished job and closes its attempt.
+func (w *Worker) record(log *logrus.Entry, bj *repository.BackgroundJob, err error) {
+	var retryAt *retryAtError
+	var pause *pauseError
+	outcome := "failed"
+	switch {
+	case err == nil:
+		outcome = "completed"
+		w.queue.MarkJobComplete(bj.ID)
+	case errors.As(err, &retryAt):
+		outcome = "rescheduled"
+		log.WithError(err).WithField("retry_at", retryAt.at).Warn("Rescheduling job")
+		w.queue.RescheduleJob(bj.ID, retryAt.at, err.Error())
+	case errors.As(err, &pause):
+		outcome = "paused"
+		log.WithError(err).Warn("Pausing job")
+		w.queue.PauseJob(bj.ID, err.Error())
+		if pause.typePrefix != "" {
+			w.queue.PauseUserJobs(bj.UserID, pause.typePrefix, err.Error())
+		}
+	case isPermanent(err):
+		log.WithError(err).Error("Job failed permanently")
+		w.queue.MarkJobDead(bj.ID, err.Error())
+	default:
+		retrying, ferr := w.queue.FailJob(bj, err.Error())
+		if ferr != nil {
+			log.WithError(ferr).Error("Failed to record job failure")
+		}
+		log.WithError(err).WithField("retrying", retrying).Error("Job failed")
+	}
+
+	msg := ""
+	if err != nil {
+		msg = err.Error()
+	}
+	w.finishAttempt(log, bj, outcome, msg)
+}
+
+// finishAttempt closes the attempt log entry of bj's current run.
+func (w *Worker) finishAttempt(log *logrus.Entry, bj *repository.BackgroundJob, outcome, errMsg string) {
+	if err := w.queue.FinishAttempt(bj.AttemptID, outcome, errMsg); err != nil {
+		log.WithError(err).Warn("Failed to log job attempt")
+	}
+}
+
+// ---------- Leases ----------
+
+// lease keeps a claimed job's heartbeat fresh for as long as the job keeps
+// reporting progress.
+type lease struct {
+	queue    *repository.JobQueueRepository
+	job      *repository.BackgroundJob
+	progress atomic.Int64 // unix nanos of the last progress report
+	lost     atomic.Bool
+	cancel   context.CancelFunc
+}
+
+type leaseKey struct{}
+
+// Progress tells a long job's lease that it is still moving. Jobs that
+// never call it get StallTimeout in total.
+func Progress(ctx context.Context) {
+	if l, ok := ctx.Value(leaseKey{}).(*lease); ok {
+		l.progress.Store(time.Now().UnixNano())
+	}
+}
+
+// keepAlive heartbeats until ctx ends. When the job stalls or the lease is
+// taken away, it cancels the job and leaves it to the reaper.
+func (l *lease) keepAlive(ctx context.Context, log *logrus.Entry) {
+	t := time.NewTicker(LeaseTTL / 4)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		if time.Since(time.Unix(0, l.progress.Load())) > StallTimeout {
+			log.Warn("Job stalled, giving up its lease")
+			l.lost.Store(true)
+			l.cancel()
+			return
+		}
+		if err := l.queue.Heartbeat(l.job); errors.Is(err, repository.ErrLeaseLost) {
+			log.Warn("Job lease lost")
+			l.lost.Store(true)
+			l.cancel()
+			return
+		} else if err != nil {
+			// One missed beat is fine; the TTL covers several.
+			log.WithError(err).Warn("Failed to heartbeat job")
+		}
+	}
+}
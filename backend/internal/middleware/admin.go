@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gant123/jobTracker/internal/config"
+)
+
+// Admin lets through only users whose email is listed in ADMIN_EMAILS.
+// It must run after Auth.
+func Admin(cfg *config.Config) func(http.Handler) http.Handler {
+	admins := map[string]bool{}
+	for _, e := range strings.Split(cfg.AdminEmails, ",") {
+		if e = strings.ToLower(strings.TrimSpace(e)); e != "" {
+			admins[e] = true
+		}
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			email, _ := r.Context().Value("user_email").(string)
+			if !admins[strings.ToLower(email)] {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// QueuedJob is a background job as users and admins see it.
+type QueuedJob struct {
+	ID           int             `json:"id"`
+	Type         string          `json:"type"`
+	UserID       *int            `json:"user_id,omitempty"` // nil for system jobs
+	Payload      json.RawMessage `json:"payload,omitempty"`
+	Status       string          `json:"status"`   // pending|processing|completed|dead|paused|cancelled
+	Priority     string          `json:"priority"` // interactive|background|maintenance
+	Attempts     int             `json:"attempts"`
+	MaxAttempts  int             `json:"max_attempts"`
+	Error        string          `json:"error,omitempty"`
+	WorkerID     string          `json:"worker_id,omitempty"`
+	ProcessAfter time.Time       `json:"process_after"`
+	HeartbeatAt  *time.Time      `json:"heartbeat_at,omitempty"`
+	CreatedAt    time.Time       `json:"created_at"`
+	UpdatedAt    time.Time       `json:"updated_at"`
+}
+
+type QueuedJobFilter struct {
+	UserID *int
+	Type   string
+	Status string
+	Limit  int
+	Offset int
+}
+
+// QueueDepth counts one job type's unfinished and dead jobs.
+type QueueDepth struct {
+	Type       string     `json:"type"`
+	Pending    int        `json:"pending"`
+	Due        int        `json:"due"` // pending and ready to run now
+	Processing int        `json:"processing"`
+	Paused     int        `json:"paused"`
+	Dead       int        `json:"dead"` // failed for good, waiting to be replayed
+	OldestDue  *time.Time `json:"oldest_due,omitempty"`
+}
+
+// JobAttempt is one run of a background job.
+type JobAttempt struct {
+	ID         int        `json:"id"`
+	JobID      int        `json:"job_id"`
+	Attempt    int        `json:"attempt"`
+	WorkerID   string     `json:"worker_id"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Outcome    string     `json:"outcome"` // running|completed|failed|rescheduled|paused|interrupted|lost|cancelled
+	Error      string     `json:"error,omitempty"`
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+)
+
+// ClassifierCorrection records a user fixing a field the Gmail scanner
+// filled in, together with the email features the scanner saw.
+type ClassifierCorrection struct {
+	ID             int       `json:"id"`
+	UserID         int       `json:"user_id"`
+	JobID          *int      `json:"job_id,omitempty"`
+	GmailMessageID string    `json:"gmail_message_id"`
+	Field          string    `json:"field"` // status|company|position
+	OldValue       string    `json:"old_value"`
+	NewValue       string    `json:"new_value"`
+	Subject        string    `json:"subject"`
+	Snippet        string    `json:"snippet"`
+	From           string    `json:"from"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// ClassifierExample is an imported email whose scanner-assigned status the
+// user has left alone, taken as confirmed.
+type ClassifierExample struct {
+	Status  string
+	Subject string
+	Snippet string
+	From    string
+}
+
+// ClassifierInfo summarises a user's learned model.
+type ClassifierInfo struct {
+	Trained     bool       `json:"trained"`
+	Version     int        `json:"version,omitempty"`
+	Examples    int        `json:"examples"`
+	Corrections int        `json:"corrections"`
+	Accuracy    float64    `json:"accuracy"`
+	TrainedAt   *time.Time `json:"trained_at,omitempty"`
+}
@@ -14,6 +14,7 @@ type Job struct {
 	SalaryMin      *int       `json:"salary_min,omitempty"`
 	SalaryMax      *int       `json:"salary_max,omitempty"`
 	Currency       string     `json:"currency,omitempty"`
+	SalaryPeriod   string     `json:"salary_period,omitempty"` // hour|day|week|month|year
 	Status         string     `json:"status"`
 	URL            string     `json:"url,omitempty"`
 	Description    string     `json:"description,omitempty"`
@@ -23,6 +24,7 @@ type Job struct {
 	CreatedAt      time.Time  `json:"created_at"`
 	UpdatedAt      time.Time  `json:"updated_at"`
 	GmailMessageID string     `json:"gmail_message_id,omitempty"`
+	SourceKey      string     `json:"source_key,omitempty"`
 }
 
 type CreateJobRequest struct {
@@ -33,6 +35,8 @@ type CreateJobRequest struct {
 	SalaryMin      *int       `json:"salary_min,omitempty"`
 	SalaryMax      *int       `json:"salary_max,omitempty"`
 	Currency       string     `json:"currency,omitempty"`
+	SalaryPeriod   string     `json:"salary_period,omitempty"`
+	Salary         string     `json:"salary,omitempty"` // free text ("$120k–$140k"), read when the fields above are empty
 	Status         string     `json:"status,omitempty"`
 	URL            string     `json:"url,omitempty"`
 	Description    string     `json:"description,omitempty"`
@@ -40,6 +44,13 @@ type CreateJobRequest struct {
 	AppliedDate    *time.Time `json:"applied_date,omitempty"`
 	InterviewDate  *time.Time `json:"interview_date,omitempty"`
 	GmailMessageID string     `json:"gmail_message_id,omitempty"`
+	SourceKey      string     `json:"source_key,omitempty"`
+	// SourceEmail, if set, is linked to the new job instead of being
+	// copied into Notes.
+	SourceEmail *JobEmailInput `json:"source_email,omitempty"`
+	// Contacts to attach to the new job (e.g. the recipient of an
+	// application the user sent directly).
+	Contacts []JobContactInput `json:"contacts,omitempty"`
 }
 
 type UpdateJobRequest struct {
@@ -50,6 +61,8 @@ type UpdateJobRequest struct {
 	SalaryMin     *int       `json:"salary_min,omitempty"`
 	SalaryMax     *int       `json:"salary_max,omitempty"`
 	Currency      string     `json:"currency,omitempty"`
+	SalaryPeriod  string     `json:"salary_period,omitempty"`
+	Salary        string     `json:"salary,omitempty"`
 	Status        string     `json:"status,omitempty"`
 	URL           string     `json:"url,omitempty"`
 	Description   string     `json:"description,omitempty"`
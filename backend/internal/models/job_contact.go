@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+)
+
+// JobContact is a person tied to a job: a recruiter, a hiring manager or
+// whoever the user emailed their application to.
+type JobContact struct {
+	ID        int       `json:"id"`
+	JobID     int       `json:"job_id"`
+	UserID    int       `json:"user_id"`
+	Name      string    `json:"name,omitempty"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role,omitempty"`   // recipient|recruiter|...
+	Source    string    `json:"source,omitempty"` // gmail|manual
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type JobContactInput struct {
+	Name  string `json:"name,omitempty"`
+	Email string `json:"email"`
+	Role  string `json:"role,omitempty"`
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+)
+
+// JobEmail is one message in a job's correspondence timeline.
+type JobEmail struct {
+	ID             int        `json:"id"`
+	JobID          int        `json:"job_id"`
+	UserID         int        `json:"user_id"`
+	GmailMessageID string     `json:"gmail_message_id"`
+	ThreadID       string     `json:"thread_id,omitempty"`
+	Subject        string     `json:"subject"`
+	From           string     `json:"from"`
+	Snippet        string     `json:"snippet,omitempty"`
+	ReceivedAt     *time.Time `json:"received_at,omitempty"`
+	Direction      string     `json:"direction"`      // inbound|outbound
+	Classification string     `json:"classification"` // status the scanner assigned
+	Link           string     `json:"link,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// JobEmailInput lets a client attach the source email when creating a job
+// from a scan result.
+type JobEmailInput struct {
+	GmailMessageID string     `json:"gmail_message_id"`
+	ThreadID       string     `json:"thread_id,omitempty"`
+	Subject        string     `json:"subject,omitempty"`
+	From           string     `json:"from,omitempty"`
+	Snippet        string     `json:"snippet,omitempty"`
+	ReceivedAt     *time.Time `json:"received_at,omitempty"`
+	Direction      string     `json:"direction,omitempty"`
+	Classification string     `json:"classification,omitempty"`
+	Link           string     `json:"link,omitempty"`
+}
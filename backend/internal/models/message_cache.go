@@ -0,0 +1,25 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// CachedMessage is a Gmail message's headers as fetched once, plus the
+// scanner's verdict on it for one classifier version.
+type CachedMessage struct {
+	UserID         int
+	GmailMessageID string
+	ThreadID       string
+	Subject        string
+	From           string
+	To             string
+	Snippet        string
+	Date           time.Time
+	// Version the verdict was produced with; stale verdicts are recomputed
+	// from the cached headers.
+	ClassifierVersion string
+	Class             string          // sent|digest|lead|inbound
+	Events            json.RawMessage // []EmailJobEvent, empty when not a job
+	FetchedAt         time.Time
+}
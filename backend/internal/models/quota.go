@@ -0,0 +1,14 @@
+package models
+
+import (
+	"time"
+)
+
+// QuotaUsage is the Gmail API quota one user consumed on one (UTC) day.
+type QuotaUsage struct {
+	UserID    int       `json:"user_id"`
+	Day       time.Time `json:"day"`
+	Units     int64     `json:"units"`
+	Requests  int64     `json:"requests"`
+	Throttled int64     `json:"throttled"` // responses that were 429s
+}
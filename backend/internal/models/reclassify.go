@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+)
+
+// ReclassifyCandidate is a Gmail-imported job as the re-classification job
+// sees it: the values stored now and which of them the user typed in.
+type ReclassifyCandidate struct {
+	JobID          int
+	GmailMessageID string
+	Direction      string // inbound|outbound, from the source email
+	Company        string
+	Position       string
+	Location       string
+	Status         string
+	Edited         map[string]bool // field -> edited by hand
+}
+
+// ReclassifyProposal is one field the current extractors would fill in
+// differently from what was stored at import time.
+type ReclassifyProposal struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"user_id"`
+	JobID     int       `json:"job_id"`
+	Field     string    `json:"field"` // company|position|location|status
+	OldValue  string    `json:"old_value"`
+	NewValue  string    `json:"new_value"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ApplyProposalsRequest picks proposals to apply; All applies every one.
+type ApplyProposalsRequest struct {
+	IDs []int `json:"ids"`
+	All bool  `json:"all"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// JobSchedule queues a background job whenever its cron expression comes
+// due. System schedules belong to no user.
+type JobSchedule struct {
+	ID        int             `json:"id"`
+	UserID    *int            `json:"user_id,omitempty"`
+	JobType   string          `json:"job_type"`
+	Payload   json.RawMessage `json:"payload"`
+	Cron      string          `json:"cron"`     // "0 */6 * * *"
+	Timezone  string          `json:"timezone"` // IANA name the cron is read in
+	Enabled   bool            `json:"enabled"`
+	NextRunAt time.Time       `json:"next_run_at"`
+	LastRunAt *time.Time      `json:"last_run_at,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+type JobScheduleRequest struct {
+	JobType  string          `json:"job_type"`
+	Payload  json.RawMessage `json:"payload,omitempty"`
+	Cron     string          `json:"cron"`
+	Timezone string          `json:"timezone"`
+}
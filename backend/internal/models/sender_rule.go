@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+)
+
+// SenderRule allows or blocks a sender address or a whole domain in the
+// user's Gmail scans.
+type SenderRule struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"user_id"`
+	Pattern   string    `json:"pattern"` // jobs@acme.com or acme.com
+	Kind      string    `json:"kind"`    // allow|block
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type SenderRuleRequest struct {
+	Pattern string `json:"pattern"`
+	Kind    string `json:"kind"`
+}
+
+// NotAJobRequest is the body of the "not a job email" action.
+type NotAJobRequest struct {
+	Block string `json:"block,omitempty"` // ""|sender|domain
+}
+
+// NotAJobResult tells the client what was dismissed and what it could block.
+type NotAJobResult struct {
+	GmailMessageID string      `json:"gmail_message_id"`
+	Sender         string      `json:"sender,omitempty"`
+	Domain         string      `json:"domain,omitempty"`
+	Blocked        *SenderRule `json:"blocked,omitempty"`
+}
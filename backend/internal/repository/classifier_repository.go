@@ -0,0 +1,168 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/gant123/jobTracker/internal/models"
+	"github.com/lib/pq"
+)
+
+type ClassifierRepository struct {
+	db *sql.DB
+}
+
+func NewClassifierRepository(db *sql.DB) *ClassifierRepository {
+	return &ClassifierRepository{db: db}
+}
+
+func (r *ClassifierRepository) RecordCorrection(c *models.ClassifierCorrection) error {
+	err := r.db.QueryRow(`
+        INSERT INTO classifier_corrections (
+            user_id, job_id, gmail_message_id, field, old_value, new_value,
+            subject, snippet, from_address
+        ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+        RETURNING id, created_at
+    `, c.UserID, c.JobID, c.GmailMessageID, c.Field, c.OldValue, c.NewValue,
+		c.Subject, c.Snippet, c.From,
+	).Scan(&c.ID, &c.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record correction: %w", err)
+	}
+	return nil
+}
+
+// ListCorrections returns the latest correction per message and field;
+// if a user fixed the same field twice, only the final answer counts.
+func (r *ClassifierRepository) ListCorrections(userID int) ([]*models.ClassifierCorrection, error) {
+	rows, err := r.db.Query(`
+        SELECT DISTINCT ON (gmail_message_id, field)
+               id, user_id, job_id, gmail_message_id, field, old_value, new_value,
+               subject, snippet, from_address, created_at
+        FROM classifier_corrections
+        WHERE user_id = $1
+        ORDER BY gmail_message_id, field, created_at DESC, id DESC
+    `, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list corrections: %w", err)
+	}
+	defer rows.Close()
+
+	var out []*models.ClassifierCorrection
+	for rows.Next() {
+		c := &models.ClassifierCorrection{}
+		if err := rows.Scan(
+			&c.ID, &c.UserID, &c.JobID, &c.GmailMessageID, &c.Field, &c.OldValue, &c.NewValue,
+			&c.Subject, &c.Snippet, &c.From, &c.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan correction: %w", err)
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+// ListConfirmedImports returns up to limit of the user's Gmail imports,
+// newest first, that still have the status the scanner gave their source
+// email and were never corrected or edited by hand. statuses limits the
+// labels returned.
+func (r *ClassifierRepository) ListConfirmedImports(userID int, statuses []string, limit int) ([]*models.ClassifierExample, error) {
+	rows, err := r.db.Query(`
+        SELECT j.status, e.subject, e.snippet, e.from_address
+        FROM jobs j
+        JOIN job_emails e ON e.job_id = j.id AND e.gmail_message_id = j.gmail_message_id
+        WHERE j.user_id = $1
+        AND j.status = ANY($2)
+        AND e.classification = j.status
+        AND NOT EXISTS (
+            SELECT 1 FROM job_field_edits f WHERE f.job_id = j.id AND f.field = 'status'
+        )
+        AND NOT EXISTS (
+            SELECT 1 FROM classifier_corrections c
+            WHERE c.user_id = j.user_id AND c.gmail_message_id = j.gmail_message_id AND c.field = 'status'
+        )
+        ORDER BY j.created_at DESC, j.id DESC
+        LIMIT $3
+    `, userID, pq.Array(statuses), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list confirmed imports: %w", err)
+	}
+	defer rows.Close()
+
+	var out []*models.ClassifierExample
+	for rows.Next() {
+		ex := &models.ClassifierExample{}
+		if err := rows.Scan(&ex.Status, &ex.Subject, &ex.Snippet, &ex.From); err != nil {
+			return nil, fmt.Errorf("failed to scan confirmed import: %w", err)
+		}
+		out = append(out, ex)
+	}
+	return out, rows.Err()
+}
+
+func (r *ClassifierRepository) CountCorrections(userID int) (int, error) {
+	var n int
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM classifier_corrections WHERE user_id = $1`, userID).Scan(&n)
+	return n, err
+}
+
+// DeleteUserData drops a user's corrections and trained model.
+func (r *ClassifierRepository) DeleteUserData(userID int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`DELETE FROM classifier_corrections WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to delete corrections: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM classifier_models WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to delete classifier model: %w", err)
+	}
+	return tx.Commit()
+}
+
+// SaveModel stores the serialized model for a user, replacing the old one.
+func (r *ClassifierRepository) SaveModel(userID int, version int, examples int, accuracy float64, model []byte) error {
+	_, err := r.db.Exec(`
+        INSERT INTO classifier_models (user_id, version, examples, accuracy, model, trained_at)
+        VALUES ($1, $2, $3, $4, $5, NOW())
+        ON CONFLICT (user_id) DO UPDATE SET
+            version = EXCLUDED.version,
+            examples = EXCLUDED.examples,
+            accuracy = EXCLUDED.accuracy,
+            model = EXCLUDED.model,
+            trained_at = NOW()
+    `, userID, version, examples, accuracy, model)
+	return err
+}
+
+// GetModel returns the serialized model; sql.ErrNoRows if none was trained.
+func (r *ClassifierRepository) GetModel(userID int) ([]byte, error) {
+	var model []byte
+	err := r.db.QueryRow(`SELECT model FROM classifier_models WHERE user_id = $1`, userID).Scan(&model)
+	return model, err
+}
+
+func (r *ClassifierRepository) GetInfo(userID int) (*models.ClassifierInfo, error) {
+	info := &models.ClassifierInfo{}
+	var trainedAt time.Time
+	err := r.db.QueryRow(`
+        SELECT version, examples, accuracy, trained_at FROM classifier_models WHERE user_id = $1
+    `, userID).Scan(&info.Version, &info.Examples, &info.Accuracy, &trainedAt)
+	switch {
+	case err == sql.ErrNoRows:
+	case err != nil:
+		return nil, fmt.Errorf("failed to get classifier info: %w", err)
+	default:
+		info.Trained = true
+		info.TrainedAt = &trainedAt
+	}
+
+	info.Corrections, err = r.CountCorrections(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count corrections: %w", err)
+	}
+	return info, nil
+}
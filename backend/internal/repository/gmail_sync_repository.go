@@ -52,6 +52,41 @@ func (r *GmailSyncRepository) UpdateSyncCompleted(userID int, totalImported int)
 	return err
 }
 
+// AddImported counts jobs found by a catch-up sync, which unlike the
+// initial one doesn't see everything.
+func (r *GmailSyncRepository) AddImported(userID int, imported int) error {
+	_, err := r.db.Exec(`
+        UPDATE gmail_sync_status 
+        SET total_imported = total_imported + $2, updated_at = NOW()
+        WHERE user_id = $1
+    `, userID, imported)
+	return err
+}
+
+// SyncedUserIDs lists users whose mailbox was imported once and whose
+// Gmail connection still works.
+func (r *GmailSyncRepository) SyncedUserIDs() ([]int, error) {
+	rows, err := r.db.Query(`
+        SELECT s.user_id FROM gmail_sync_status s
+        JOIN email_tokens t ON t.user_id = s.user_id AND t.provider = 'gmail'
+        WHERE s.initial_sync_completed AND NOT COALESCE(t.needs_reauth, FALSE)
+        ORDER BY s.user_id
+    `)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
 func (r *GmailSyncRepository) UpdateLastHistoryID(userID int, historyID string) error {
 	_, err := r.db.Exec(`
         UPDATE gmail_sync_status 
@@ -61,6 +96,11 @@ func (r *GmailSyncRepository) UpdateLastHistoryID(userID int, historyID string)
 	return err
 }
 
+func (r *GmailSyncRepository) Delete(userID int) error {
+	_, err := r.db.Exec(`DELETE FROM gmail_sync_status WHERE user_id = $1`, userID)
+	return err
+}
+
 type GmailSyncStatus struct {
 	ID                     int
 	UserID                 int
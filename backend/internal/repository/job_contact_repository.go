@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/gant123/jobTracker/internal/models"
+)
+
+type JobContactRepository struct {
+	db *sql.DB
+}
+
+func NewJobContactRepository(db *sql.DB) *JobContactRepository {
+	return &JobContactRepository{db: db}
+}
+
+// Create adds a contact to a job. Adding the same address twice is a no-op.
+func (r *JobContactRepository) Create(c *models.JobContact) error {
+	c.Email = strings.ToLower(strings.TrimSpace(c.Email))
+	err := r.db.QueryRow(`
+        INSERT INTO job_contacts (job_id, user_id, name, email, role, source)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        ON CONFLICT (job_id, email) DO NOTHING
+        RETURNING id, created_at
+    `, c.JobID, c.UserID, c.Name, c.Email, c.Role, c.Source).Scan(&c.ID, &c.CreatedAt)
+	if err == sql.ErrNoRows {
+		return ErrDuplicate
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create job contact: %w", err)
+	}
+	return nil
+}
+
+func (r *JobContactRepository) GetByJobID(jobID int, userID int) ([]*models.JobContact, error) {
+	rows, err := r.db.Query(`
+        SELECT id, job_id, user_id, name, email, role, source, created_at
+        FROM job_contacts
+        WHERE job_id = $1 AND user_id = $2
+        ORDER BY id
+    `, jobID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job contacts: %w", err)
+	}
+	defer rows.Close()
+
+	contacts := []*models.JobContact{}
+	for rows.Next() {
+		c := &models.JobContact{}
+		if err := rows.Scan(&c.ID, &c.JobID, &c.UserID, &c.Name, &c.Email, &c.Role, &c.Source, &c.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan job contact: %w", err)
+		}
+		contacts = append(contacts, c)
+	}
+
+	return contacts, rows.Err()
+}
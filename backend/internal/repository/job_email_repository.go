@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/gant123/jobTracker/internal/models"
+)
+
+type JobEmailRepository struct {
+	db *sql.DB
+}
+
+func NewJobEmailRepository(db *sql.DB) *JobEmailRepository {
+	return &JobEmailRepository{db: db}
+}
+
+// Create links a message to a job. Linking the same message twice is a no-op.
+func (r *JobEmailRepository) Create(e *models.JobEmail) error {
+	if e.Direction == "" {
+		e.Direction = "inbound"
+	}
+	err := r.db.QueryRow(`
+        INSERT INTO job_emails (
+            job_id, user_id, gmail_message_id, thread_id, subject, from_address,
+            snippet, received_at, direction, classification, link
+        ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+        ON CONFLICT (job_id, gmail_message_id) DO NOTHING
+        RETURNING id, created_at
+    `,
+		e.JobID,
+		e.UserID,
+		e.GmailMessageID,
+		e.ThreadID,
+		e.Subject,
+		e.From,
+		e.Snippet,
+		e.ReceivedAt,
+		e.Direction,
+		e.Classification,
+		e.Link,
+	).Scan(&e.ID, &e.CreatedAt)
+	if err == sql.ErrNoRows {
+		return ErrDuplicate
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create job email: %w", err)
+	}
+	return nil
+}
+
+// GetByJobID returns the correspondence timeline for a job, oldest first.
+func (r *JobEmailRepository) GetByJobID(jobID int, userID int) ([]*models.JobEmail, error) {
+	rows, err := r.db.Query(`
+        SELECT id, job_id, user_id, gmail_message_id, thread_id, subject, from_address,
+               snippet, received_at, direction, classification, link, created_at
+        FROM job_emails
+        WHERE job_id = $1 AND user_id = $2
+        ORDER BY received_at ASC NULLS LAST, id ASC
+    `, jobID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job emails: %w", err)
+	}
+	defer rows.Close()
+
+	emails := []*models.JobEmail{}
+	for rows.Next() {
+		e := &models.JobEmail{}
+		if err := rows.Scan(
+			&e.ID,
+			&e.JobID,
+			&e.UserID,
+			&e.GmailMessageID,
+			&e.ThreadID,
+			&e.Subject,
+			&e.From,
+			&e.Snippet,
+			&e.ReceivedAt,
+			&e.Direction,
+			&e.Classification,
+			&e.Link,
+			&e.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan job email: %w", err)
+		}
+		emails = append(emails, e)
+	}
+
+	return emails, rows.Err()
+}
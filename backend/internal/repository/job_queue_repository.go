@@ -3,56 +3,386 @@ package repository
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/gant123/jobTracker/internal/models"
+	"github.com/lib/pq"
 )
 
+// JobsChannel is the NOTIFY channel that tells workers a job is ready to
+// run now.
+const JobsChannel = "background_jobs"
+
+// ErrLeaseLost means a running job was taken from its worker, usually by
+// the reaper after the worker's heartbeats stopped.
+var ErrLeaseLost = errors.New("job lease lost")
+
+// userClaimLock is the advisory lock space ("jc") serializing job claims
+// per user; the second key is the user id.
+const userClaimLock = 0x6a63
+
+// ErrJobNotFound means no job with that id is in a state the action
+// applies to.
+var ErrJobNotFound = errors.New("background job not found")
+
+// Priority is the class of a job. Due jobs of a higher class run first.
+type Priority int
+
+const (
+	// Bulk upkeep nobody is waiting on, e.g. nightly re-scans.
+	PriorityMaintenance Priority = -1
+	// The default: work a user caused but isn't watching.
+	PriorityBackground Priority = 0
+	// Work a user just asked for and is waiting to see.
+	PriorityInteractive Priority = 1
+)
+
+// A due job waiting this long is treated as one class higher.
+const PriorityAging = 15 * time.Minute
+
+func (p Priority) String() string {
+	switch {
+	case p >= PriorityInteractive:
+		return "interactive"
+	case p <= PriorityMaintenance:
+		return "maintenance"
+	}
+	return "background"
+}
+
+// RetryPolicy says how often a job type is tried and how long to wait
+// between tries. The wait doubles from BaseDelay up to MaxDelay; half of it
+// is random so jobs that failed together don't retry together.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   30 * time.Second,
+	MaxDelay:    30 * time.Minute,
+}
+
+// Backoff returns how long to wait after the given attempt (1-based) failed.
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < attempt && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
 type JobQueueRepository struct {
 	db *sql.DB
+
+	mu       sync.RWMutex
+	policies map[string]RetryPolicy
+	perUser  int
 }
 
 func NewJobQueueRepository(db *sql.DB) *JobQueueRepository {
-	return &JobQueueRepository{db: db}
+	return &JobQueueRepository{db: db, policies: map[string]RetryPolicy{}, perUser: 1}
+}
+
+// SetRetryPolicy overrides DefaultRetryPolicy for one job type. Jobs
+// already queued keep the attempt limit they were created with.
+func (r *JobQueueRepository) SetRetryPolicy(jobType string, p RetryPolicy) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.policies[jobType] = p
 }
 
-func (r *JobQueueRepository) CreateJob(jobType string, userID int, payload interface{}) error {
+func (r *JobQueueRepository) RetryPolicy(jobType string) RetryPolicy {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if p, ok := r.policies[jobType]; ok {
+		return p
+	}
+	return DefaultRetryPolicy
+}
+
+// CreateJob queues a job for userID, or a system job when userID is 0.
+func (r *JobQueueRepository) CreateJob(jobType string, userID int, priority Priority, payload interface{}) error {
+	return r.createJob(jobType, userID, priority, nil, payload)
+}
+
+// CreateUniqueJob queues a job unless the user already has one of this
+// type with the same key waiting or running, in which case it returns
+// ErrDuplicate. The job already queued is moved up to priority if that's
+// higher, so clicking "sync" on a queued nightly sync hurries it along.
+func (r *JobQueueRepository) CreateUniqueJob(jobType string, userID int, priority Priority, key string, payload interface{}) error {
+	return r.createJob(jobType, userID, priority, &key, payload)
+}
+
+func (r *JobQueueRepository) createJob(jobType string, userID int, priority Priority, key *string, payload interface{}) error {
 	payloadJSON, err := json.Marshal(payload)
 	if err != nil {
 		return err
 	}
+	var owner *int
+	if userID != 0 {
+		owner = &userID
+	}
 
-	_, err = r.db.Exec(`
-        INSERT INTO background_jobs (type, user_id, payload, status, process_after)
-        VALUES ($1, $2, $3, 'pending', NOW())
-    `, jobType, userID, payloadJSON)
+	// The notification goes out when the insert commits. xmax is 0 for a
+	// row this statement inserted rather than updated.
+	var inserted bool
+	err = r.db.QueryRow(`
+        WITH job AS (
+            INSERT INTO background_jobs (type, user_id, payload, status, process_after, max_attempts, unique_key, priority)
+            VALUES ($1, $2, $3, 'pending', NOW(), $4, $6, $7)
+            ON CONFLICT (type, COALESCE(user_id, 0), unique_key)
+                WHERE unique_key IS NOT NULL AND status IN ('pending', 'processing')
+                DO UPDATE SET priority = GREATEST(background_jobs.priority, EXCLUDED.priority)
+            RETURNING type, xmax = 0 AS inserted
+        )
+        SELECT inserted FROM job, pg_notify($5, job.type)
+    `, jobType, owner, payloadJSON, max(r.RetryPolicy(jobType).MaxAttempts, 1), JobsChannel, key, priority).Scan(&inserted)
+	if err != nil {
+		return err
+	}
+	if !inserted {
+		return ErrDuplicate
+	}
+	return nil
+}
 
+// SetUserConcurrency caps how many jobs of one user run at once across
+// all workers. System jobs aren't capped.
+func (r *JobQueueRepository) SetUserConcurrency(n int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.perUser = max(n, 1)
+}
+
+func (r *JobQueueRepository) userConcurrency() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.perUser
+}
+
+// notify wakes the workers for jobs that became due without CreateJob.
+func (r *JobQueueRepository) notify() error {
+	_, err := r.db.Exec(`SELECT pg_notify($1, '')`, JobsChannel)
 	return err
 }
 
-func (r *JobQueueRepository) GetNextJob() (*BackgroundJob, error) {
-	var job BackgroundJob
-	var payloadJSON []byte
+// NextDueIn returns how long until the earliest pending job becomes due
+// (negative if one is overdue), so a worker with nothing to do knows how
+// long it may sleep. Jobs of users at their concurrency limit don't count;
+// the worker finishing their running job picks the next one up.
+func (r *JobQueueRepository) NextDueIn() (time.Duration, bool, error) {
+	var secs sql.NullFloat64
+	err := r.db.QueryRow(`
+        SELECT EXTRACT(EPOCH FROM MIN(process_after) - NOW())::float8 FROM background_jobs j
+        WHERE status = 'pending' AND attempts < max_attempts
+        AND `+userHasRoom+`
+    `, r.userConcurrency()).Scan(&secs)
+	return time.Duration(secs.Float64 * float64(time.Second)), secs.Valid, err
+}
+
+// HasPendingJob reports whether a user already has a job of this type
+// waiting to run.
+func (r *JobQueueRepository) HasPendingJob(jobType string, userID int) (bool, error) {
+	var exists bool
+	err := r.db.QueryRow(`
+        SELECT EXISTS (
+            SELECT 1 FROM background_jobs
+            WHERE type = $1 AND user_id = $2 AND status = 'pending'
+        )
+    `, jobType, userID).Scan(&exists)
+	return exists, err
+}
 
+// HasActiveJob reports whether a user has a job of this type waiting or
+// running.
+func (r *JobQueueRepository) HasActiveJob(jobType string, userID int) (bool, error) {
+	var exists bool
 	err := r.db.QueryRow(`
+        SELECT EXISTS (
+            SELECT 1 FROM background_jobs
+            WHERE type = $1 AND user_id = $2 AND status IN ('pending', 'processing')
+        )
+    `, jobType, userID).Scan(&exists)
+	return exists, err
+}
+
+// userHasRoom is true for jobs j whose user runs fewer jobs than the
+// limit passed as $1. System jobs always have room.
+const userHasRoom = `(j.user_id IS NULL OR (
+            SELECT COUNT(*) FROM background_jobs p
+            WHERE p.user_id = j.user_id AND p.status = 'processing'
+        ) < $1)`
+
+// errUserBusy means the job picked was of a user whose limit another
+// worker filled first.
+var errUserBusy = errors.New("user at job concurrency limit")
+
+// GetNextJob claims the next due job for workerID and starts its
+// heartbeat. Users at their concurrency limit are skipped, so one user's
+// jobs run serially by default.
+func (r *JobQueueRepository) GetNextJob(workerID string) (*BackgroundJob, error) {
+	for range 3 {
+		job, err := r.claimNext(workerID)
+		if err != errUserBusy {
+			return job, err
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *JobQueueRepository) claimNext(workerID string) (*BackgroundJob, error) {
+	limit := r.userConcurrency()
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Higher classes go first, and a job moves up a class for every
+	// PriorityAging it has been due, so maintenance isn't starved. Within a
+	// class users take turns: everyone's oldest job comes before anyone's
+	// second, so one user's bulk work can't hold up the rest.
+	var id int
+	var userID sql.NullInt64
+	err = tx.QueryRow(`
+        SELECT j.id, j.user_id FROM background_jobs j
+        JOIN (
+            SELECT id, class,
+                   ROW_NUMBER() OVER (PARTITION BY user_id, class ORDER BY created_at) AS turn
+            FROM (
+                SELECT id, user_id, created_at,
+                       LEAST(priority + FLOOR(EXTRACT(EPOCH FROM NOW() - process_after) / $2)::int, $3) AS class
+                FROM background_jobs j
+                WHERE status = 'pending' 
+                AND process_after <= NOW()
+                AND attempts < max_attempts
+                AND `+userHasRoom+`
+            ) due
+        ) ranked ON ranked.id = j.id
+        ORDER BY ranked.class DESC, ranked.turn, j.created_at
+        LIMIT 1
+        FOR UPDATE OF j SKIP LOCKED
+    `, limit, PriorityAging.Seconds(), PriorityInteractive).Scan(&id, &userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if userID.Valid {
+		// Two workers may have picked jobs of the same user at once. Claims
+		// for a user take turns, and each recounts after the one before it
+		// committed.
+		if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1, $2)`, userClaimLock, userID.Int64); err != nil {
+			return nil, err
+		}
+		var running int
+		if err := tx.QueryRow(`
+            SELECT COUNT(*) FROM background_jobs WHERE user_id = $1 AND status = 'processing'
+        `, userID.Int64).Scan(&running); err != nil {
+			return nil, err
+		}
+		if running >= limit {
+			return nil, errUserBusy
+		}
+	}
+
+	job := BackgroundJob{WorkerID: workerID}
+	err = tx.QueryRow(`
         UPDATE background_jobs
         SET status = 'processing', 
             attempts = attempts + 1,
+            worker_id = $1,
+            heartbeat_at = NOW(),
             updated_at = NOW()
-        WHERE id = (
-            SELECT id FROM background_jobs
-            WHERE status = 'pending' 
-            AND process_after <= NOW()
-            AND attempts < 3
-            ORDER BY created_at
-            LIMIT 1
-            FOR UPDATE SKIP LOCKED
-        )
-        RETURNING id, type, user_id, payload, attempts
-    `).Scan(&job.ID, &job.Type, &job.UserID, &payloadJSON, &job.Attempts)
+        WHERE id = $2
+        RETURNING id, type, COALESCE(user_id, 0), payload, attempts
+    `, workerID, id).Scan(&job.ID, &job.Type, &job.UserID, &job.Payload, &job.Attempts)
+	if err != nil {
+		return nil, err
+	}
+	err = tx.QueryRow(`
+        INSERT INTO background_job_attempts (job_id, attempt, worker_id)
+        VALUES ($1, $2, $3)
+        RETURNING id
+    `, job.ID, job.Attempts, workerID).Scan(&job.AttemptID)
+	if err != nil {
+		return nil, err
+	}
+	return &job, tx.Commit()
+}
+
+// FinishAttempt closes a job's attempt log entry once the worker has
+// recorded what became of the job. errMsg may be empty.
+func (r *JobQueueRepository) FinishAttempt(attemptID int, outcome string, errMsg string) error {
+	_, err := r.db.Exec(`
+        UPDATE background_job_attempts
+        SET finished_at = NOW(), outcome = $2, error = NULLIF($3, '')
+        WHERE id = $1 AND finished_at IS NULL
+    `, attemptID, outcome, errMsg)
+	return err
+}
 
-	if err == nil && payloadJSON != nil {
-		json.Unmarshal(payloadJSON, &job.Payload)
+// Heartbeat extends the lease of a running job. ErrLeaseLost means the job
+// is no longer this worker's to finish.
+func (r *JobQueueRepository) Heartbeat(job *BackgroundJob) error {
+	res, err := r.db.Exec(`
+        UPDATE background_jobs
+        SET heartbeat_at = NOW()
+        WHERE id = $1 AND worker_id = $2 AND status = 'processing'
+    `, job.ID, job.WorkerID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrLeaseLost
 	}
+	return nil
+}
 
-	return &job, err
+// ReapExpiredJobs hands jobs whose worker stopped heartbeating more than
+// ttl ago back to the queue, or moves them to the dead-letter state if they
+// have no attempts left. Their open attempts are logged as lost. Rows
+// claimed before heartbeats existed fall back to updated_at.
+func (r *JobQueueRepository) ReapExpiredJobs(ttl time.Duration) (int64, error) {
+	var n int64
+	err := r.db.QueryRow(`
+        WITH reaped AS (
+            UPDATE background_jobs
+            SET status = CASE WHEN attempts < max_attempts THEN 'pending' ELSE 'dead' END,
+                error = 'worker ' || COALESCE(worker_id, 'unknown') || ' stopped responding',
+                process_after = NOW(),
+                updated_at = NOW()
+            WHERE status = 'processing'
+            AND COALESCE(heartbeat_at, updated_at) < NOW() - make_interval(secs => $1)
+            RETURNING id, error
+        ), lost AS (
+            UPDATE background_job_attempts a
+            SET finished_at = NOW(), outcome = 'lost', error = reaped.error
+            FROM reaped
+            WHERE a.job_id = reaped.id AND a.finished_at IS NULL
+        )
+        SELECT COUNT(*) FROM reaped
+    `, ttl.Seconds()).Scan(&n)
+	if err != nil {
+		return 0, err
+	}
+	if n > 0 {
+		r.notify()
+	}
+	return n, nil
 }
 
 func (r *JobQueueRepository) MarkJobComplete(jobID int) error {
@@ -64,19 +394,404 @@ func (r *JobQueueRepository) MarkJobComplete(jobID int) error {
 	return err
 }
 
-func (r *JobQueueRepository) MarkJobFailed(jobID int, errMsg string) error {
+// FailJob records a failed attempt. The job goes back to pending after its
+// type's backoff unless that was its last attempt, in which case it moves
+// to the dead-letter state. It reports whether the job will be retried.
+func (r *JobQueueRepository) FailJob(job *BackgroundJob, errMsg string) (bool, error) {
+	delay := r.RetryPolicy(job.Type).Backoff(job.Attempts)
+	var status string
+	err := r.db.QueryRow(`
+        UPDATE background_jobs
+        SET status = CASE WHEN attempts < max_attempts THEN 'pending' ELSE 'dead' END,
+            process_after = CASE WHEN attempts < max_attempts
+                THEN NOW() + make_interval(secs => $2) ELSE process_after END,
+            error = $1, updated_at = NOW()
+        WHERE id = $3
+        RETURNING status
+    `, errMsg, delay.Seconds(), job.ID).Scan(&status)
+	return status == "pending", err
+}
+
+// MarkJobDead moves a running job to the dead-letter state, whatever
+// attempts it has left. It stays there until replayed or pruned. A job
+// cancelled while it ran stays cancelled.
+func (r *JobQueueRepository) MarkJobDead(jobID int, errMsg string) error {
+	_, err := r.db.Exec(`
+        UPDATE background_jobs
+        SET status = 'dead', error = $1, updated_at = NOW()
+        WHERE id = $2 AND status = 'processing'
+    `, errMsg, jobID)
+	return err
+}
+
+// PauseJob parks a job that cannot make progress until the user acts
+// (e.g. reconnects Gmail). The attempt it just used is given back.
+func (r *JobQueueRepository) PauseJob(jobID int, reason string) error {
 	_, err := r.db.Exec(`
         UPDATE background_jobs 
-        SET status = 'failed', error = $1, updated_at = NOW() 
+        SET status = 'paused', attempts = GREATEST(attempts - 1, 0), error = $1, updated_at = NOW() 
         WHERE id = $2
-    `, errMsg, jobID)
+    `, reason, jobID)
 	return err
 }
 
+// RescheduleJob puts a job back in the queue to run at a later time, giving
+// back the attempt it just used (e.g. when a quota budget is spent).
+func (r *JobQueueRepository) RescheduleJob(jobID int, at time.Time, reason string) error {
+	_, err := r.db.Exec(`
+        UPDATE background_jobs 
+        SET status = 'pending', attempts = GREATEST(attempts - 1, 0), error = $1,
+            process_after = $2, updated_at = NOW() 
+        WHERE id = $3
+    `, reason, at, jobID)
+	if err == nil && !at.After(time.Now()) {
+		err = r.notify()
+	}
+	return err
+}
+
+// PauseUserJobs parks every pending job of a user whose type starts with
+// typePrefix (e.g. "gmail_").
+func (r *JobQueueRepository) PauseUserJobs(userID int, typePrefix string, reason string) error {
+	_, err := r.db.Exec(`
+        UPDATE background_jobs 
+        SET status = 'paused', error = $3, updated_at = NOW() 
+        WHERE user_id = $1 AND status = 'pending' AND type LIKE $2 || '%'
+    `, userID, typePrefix, reason)
+	return err
+}
+
+// ResumeUserJobs puts a user's paused jobs back in the queue. Of paused
+// unique jobs sharing a key only the newest resumes, and none if one like
+// it was queued in the meantime; the rest are cancelled.
+func (r *JobQueueRepository) ResumeUserJobs(userID int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+        WITH ranked AS (
+            SELECT id, ROW_NUMBER() OVER (
+                PARTITION BY type, unique_key ORDER BY created_at DESC, id DESC
+            ) AS rn
+            FROM background_jobs
+            WHERE user_id = $1 AND status = 'paused' AND unique_key IS NOT NULL
+        )
+        UPDATE background_jobs j
+        SET status = 'cancelled', error = 'superseded by a newer job', updated_at = NOW()
+        WHERE j.user_id = $1 AND j.status = 'paused' AND j.unique_key IS NOT NULL
+        AND (
+            j.id IN (SELECT id FROM ranked WHERE rn > 1)
+            OR EXISTS (
+                SELECT 1 FROM background_jobs a
+                WHERE a.type = j.type AND a.user_id = $1 AND a.unique_key = j.unique_key
+                AND a.status IN ('pending', 'processing')
+            )
+        )
+    `, userID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+        UPDATE background_jobs
+        SET status = 'pending', error = NULL, process_after = NOW(), updated_at = NOW()
+        WHERE user_id = $1 AND status = 'paused'
+    `, userID)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return r.notify()
+}
+
+// DeleteUserJobs removes a user's not-yet-running jobs whose type starts
+// with typePrefix.
+func (r *JobQueueRepository) DeleteUserJobs(userID int, typePrefix string) error {
+	_, err := r.db.Exec(`
+        DELETE FROM background_jobs
+        WHERE user_id = $1 AND status IN ('pending', 'paused') AND type LIKE $2 || '%'
+    `, userID, typePrefix)
+	return err
+}
+
+// CancelRunningUserJobs cancels a user's running jobs whose type starts
+// with typePrefix. Their workers lose the lease at the next heartbeat and
+// stop; the open attempts are logged as cancelled.
+func (r *JobQueueRepository) CancelRunningUserJobs(userID int, typePrefix string, reason string) error {
+	_, err := r.db.Exec(`
+        WITH cancelled AS (
+            UPDATE background_jobs
+            SET status = 'cancelled', error = $3, updated_at = NOW()
+            WHERE user_id = $1 AND status = 'processing' AND type LIKE $2 || '%'
+            RETURNING id
+        )
+        UPDATE background_job_attempts a
+        SET finished_at = NOW(), outcome = 'cancelled', error = $3
+        FROM cancelled
+        WHERE a.job_id = cancelled.id AND a.finished_at IS NULL
+    `, userID, typePrefix, reason)
+	return err
+}
+
+// ListJobs returns jobs matching f, newest first.
+func (r *JobQueueRepository) ListJobs(f models.QueuedJobFilter) ([]models.QueuedJob, error) {
+	query := `
+        SELECT id, type, user_id, COALESCE(payload, '{}'), status, priority, attempts, max_attempts,
+               COALESCE(error, ''), COALESCE(worker_id, ''), process_after, heartbeat_at,
+               created_at, updated_at
+        FROM background_jobs
+        WHERE TRUE
+    `
+	args := []interface{}{}
+	argCounter := 1
+
+	if f.UserID != nil {
+		query += fmt.Sprintf(" AND user_id = $%d", argCounter)
+		args = append(args, *f.UserID)
+		argCounter++
+	}
+	if f.Type != "" {
+		query += fmt.Sprintf(" AND type = $%d", argCounter)
+		args = append(args, f.Type)
+		argCounter++
+	}
+	if f.Status != "" {
+		query += fmt.Sprintf(" AND status = $%d", argCounter)
+		args = append(args, f.Status)
+		argCounter++
+	}
+
+	limit := f.Limit
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+	query += fmt.Sprintf(" ORDER BY created_at DESC, id DESC LIMIT $%d OFFSET $%d", argCounter, argCounter+1)
+	args = append(args, limit, max(f.Offset, 0))
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list background jobs: %w", err)
+	}
+	defer rows.Close()
+
+	jobs := []models.QueuedJob{}
+	for rows.Next() {
+		var j models.QueuedJob
+		var uid sql.NullInt64
+		var priority Priority
+		if err := rows.Scan(&j.ID, &j.Type, &uid, &j.Payload, &j.Status, &priority, &j.Attempts, &j.MaxAttempts,
+			&j.Error, &j.WorkerID, &j.ProcessAfter, &j.HeartbeatAt, &j.CreatedAt, &j.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan background job: %w", err)
+		}
+		j.Priority = priority.String()
+		if uid.Valid {
+			id := int(uid.Int64)
+			j.UserID = &id
+		}
+		jobs = append(jobs, j)
+	}
+	return jobs, rows.Err()
+}
+
+// CancelJob cancels one of the user's jobs that hasn't started. Running
+// jobs can't be cancelled.
+func (r *JobQueueRepository) CancelJob(jobID int, userID int) error {
+	res, err := r.db.Exec(`
+        UPDATE background_jobs
+        SET status = 'cancelled', error = 'cancelled by user', updated_at = NOW()
+        WHERE id = $1 AND user_id = $2 AND status IN ('pending', 'paused')
+    `, jobID, userID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrJobNotFound
+	}
+	return nil
+}
+
+// RetryNow queues a job that hasn't succeeded to run right away, ahead of
+// background work. A job out of attempts gets one more. ErrDuplicate means
+// an identical unique job is already queued.
+func (r *JobQueueRepository) RetryNow(jobID int) error {
+	res, err := r.db.Exec(`
+        UPDATE background_jobs
+        SET status = 'pending',
+            max_attempts = GREATEST(max_attempts, attempts + 1),
+            priority = GREATEST(priority, $2),
+            process_after = NOW(),
+            updated_at = NOW()
+        WHERE id = $1 AND status IN ('pending', 'paused', 'dead', 'cancelled')
+    `, jobID, PriorityInteractive)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrDuplicate
+	}
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrJobNotFound
+	}
+	return r.notify()
+}
+
+// ReplayJob takes a job out of the dead-letter state and queues it with a
+// fresh set of attempts, e.g. once the bug that killed it is fixed. Its
+// attempt log is kept. ErrDuplicate means an identical unique job is
+// already queued.
+func (r *JobQueueRepository) ReplayJob(jobID int) error {
+	res, err := r.db.Exec(`
+        UPDATE background_jobs
+        SET status = 'pending', attempts = 0, error = NULL, process_after = NOW(), updated_at = NOW()
+        WHERE id = $1 AND status = 'dead'
+    `, jobID)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrDuplicate
+	}
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrJobNotFound
+	}
+	return r.notify()
+}
+
+// ReplayDeadJobs replays every dead job of a type. Of dead unique jobs
+// with the same key only the latest is replayed, and none if one like it
+// is already queued.
+func (r *JobQueueRepository) ReplayDeadJobs(jobType string) (int64, error) {
+	res, err := r.db.Exec(`
+        UPDATE background_jobs j
+        SET status = 'pending', attempts = 0, error = NULL, process_after = NOW(), updated_at = NOW()
+        WHERE j.status = 'dead' AND j.type = $1
+        AND (j.unique_key IS NULL OR (
+            j.id = (
+                SELECT d.id FROM background_jobs d
+                WHERE d.status = 'dead' AND d.type = j.type AND d.unique_key = j.unique_key
+                AND COALESCE(d.user_id, 0) = COALESCE(j.user_id, 0)
+                ORDER BY d.updated_at DESC, d.id DESC
+                LIMIT 1
+            )
+            AND NOT EXISTS (
+                SELECT 1 FROM background_jobs a
+                WHERE a.type = j.type AND a.unique_key = j.unique_key
+                AND COALESCE(a.user_id, 0) = COALESCE(j.user_id, 0)
+                AND a.status IN ('pending', 'processing')
+            )
+        ))
+    `, jobType)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if n > 0 {
+		r.notify()
+	}
+	return n, err
+}
+
+// ListAttempts returns a job's attempt log, oldest first.
+func (r *JobQueueRepository) ListAttempts(jobID int) ([]models.JobAttempt, error) {
+	rows, err := r.db.Query(`
+        SELECT id, job_id, attempt, worker_id, started_at, finished_at,
+               COALESCE(outcome, 'running'), COALESCE(error, '')
+        FROM background_job_attempts
+        WHERE job_id = $1
+        ORDER BY started_at, id
+    `, jobID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list job attempts: %w", err)
+	}
+	defer rows.Close()
+
+	attempts := []models.JobAttempt{}
+	for rows.Next() {
+		var a models.JobAttempt
+		if err := rows.Scan(&a.ID, &a.JobID, &a.Attempt, &a.WorkerID, &a.StartedAt, &a.FinishedAt,
+			&a.Outcome, &a.Error); err != nil {
+			return nil, fmt.Errorf("failed to scan job attempt: %w", err)
+		}
+		attempts = append(attempts, a)
+	}
+	return attempts, rows.Err()
+}
+
+// PruneJobs deletes up to limit jobs in one of statuses that last changed
+// more than age ago, along with their attempt logs.
+func (r *JobQueueRepository) PruneJobs(age time.Duration, limit int, statuses ...string) (int64, error) {
+	res, err := r.db.Exec(`
+        DELETE FROM background_jobs WHERE id IN (
+            SELECT id FROM background_jobs
+            WHERE status = ANY($1) AND updated_at < NOW() - make_interval(secs => $2)
+            LIMIT $3
+        )
+    `, pq.Array(statuses), age.Seconds(), limit)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// PruneAttempts deletes up to limit finished attempt log entries that
+// started more than age ago.
+func (r *JobQueueRepository) PruneAttempts(age time.Duration, limit int) (int64, error) {
+	res, err := r.db.Exec(`
+        DELETE FROM background_job_attempts WHERE id IN (
+            SELECT id FROM background_job_attempts
+            WHERE finished_at IS NOT NULL AND started_at < NOW() - make_interval(secs => $1)
+            LIMIT $2
+        )
+    `, age.Seconds(), limit)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// QueueDepths counts unfinished and dead jobs per type.
+func (r *JobQueueRepository) QueueDepths() ([]models.QueueDepth, error) {
+	rows, err := r.db.Query(`
+        SELECT type,
+               COUNT(*) FILTER (WHERE status = 'pending'),
+               COUNT(*) FILTER (WHERE status = 'pending' AND process_after <= NOW()),
+               COUNT(*) FILTER (WHERE status = 'processing'),
+               COUNT(*) FILTER (WHERE status = 'paused'),
+               COUNT(*) FILTER (WHERE status = 'dead'),
+               MIN(process_after) FILTER (WHERE status = 'pending' AND process_after <= NOW())
+        FROM background_jobs
+        WHERE status IN ('pending', 'processing', 'paused', 'dead')
+        GROUP BY type
+        ORDER BY type
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count background jobs: %w", err)
+	}
+	defer rows.Close()
+
+	depths := []models.QueueDepth{}
+	for rows.Next() {
+		var d models.QueueDepth
+		if err := rows.Scan(&d.Type, &d.Pending, &d.Due, &d.Processing, &d.Paused, &d.Dead, &d.OldestDue); err != nil {
+			return nil, fmt.Errorf("failed to scan queue depth: %w", err)
+		}
+		depths = append(depths, d)
+	}
+	return depths, rows.Err()
+}
+
 type BackgroundJob struct {
-	ID       int
-	Type     string
-	UserID   int
-	Payload  map[string]interface{}
-	Attempts int
+	ID        int
+	Type      string
+	UserID    int             // 0 for system jobs
+	Payload   json.RawMessage // decoded by the job type's handler
+	Attempts  int
+	WorkerID  string
+	AttemptID int // this run's background_job_attempts row
 }
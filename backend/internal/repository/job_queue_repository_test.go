@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 5, BaseDelay: 30 * time.Second, MaxDelay: 5 * time.Minute}
+	tests := []struct {
+		policy  RetryPolicy
+		attempt int
+		full    time.Duration // before jitter; the wait is between half and all of it
+	}{
+		{p, 1, 30 * time.Second},
+		{p, 2, time.Minute},
+		{p, 3, 2 * time.Minute},
+		{p, 4, 4 * time.Minute},
+		{p, 5, 5 * time.Minute},
+		{p, 50, 5 * time.Minute},
+		{p, 0, 30 * time.Second},
+		{DefaultRetryPolicy, 1, 30 * time.Second},
+		{DefaultRetryPolicy, 7, 30 * time.Minute},
+		{RetryPolicy{BaseDelay: time.Hour, MaxDelay: time.Minute}, 1, time.Minute},
+		{RetryPolicy{MaxAttempts: 3}, 2, 0},
+	}
+	for _, tt := range tests {
+		for range 100 {
+			got := tt.policy.Backoff(tt.attempt)
+			if got < tt.full/2 || got > tt.full {
+				t.Errorf("%+v.Backoff(%d) = %s, want between %s and %s",
+					tt.policy, tt.attempt, got, tt.full/2, tt.full)
+				break
+			}
+		}
+	}
+}
@@ -22,12 +22,13 @@ func (r *JobRepository) Create(job *models.Job) error {
         INSERT INTO jobs (
             user_id, company, position, location, job_type,
             salary_min, salary_max, currency, status, url,
-            description, notes, applied_date, interview_date, gmail_message_id
-        ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
-        ON CONFLICT (gmail_message_id) DO NOTHING
+            description, notes, applied_date, interview_date, gmail_message_id,
+            source_key, salary_period
+        ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, NULLIF($15, ''), $16, $17)
+        ON CONFLICT (user_id, gmail_message_id, source_key) DO NOTHING
         RETURNING id, created_at, updated_at
     `
-	// Add job.GmailMessageID as the 15th paramete
+	// Manual jobs have no message id; NULLs never conflict with each other.
 	err := r.db.QueryRow(
 		query,
 		job.UserID,
@@ -45,6 +46,8 @@ func (r *JobRepository) Create(job *models.Job) error {
 		job.AppliedDate,
 		job.InterviewDate,
 		job.GmailMessageID,
+		job.SourceKey,
+		job.SalaryPeriod,
 	).Scan(&job.ID, &job.CreatedAt, &job.UpdatedAt)
 	if err != nil {
 		// If the error is "no rows", it means our ON CONFLICT was triggered.
@@ -66,7 +69,7 @@ func (r *JobRepository) GetByID(id int, userID int) (*models.Job, error) {
             id, user_id, company, position, location, job_type,
             salary_min, salary_max, currency, status, url,
             description, notes, applied_date, interview_date,
-            created_at, updated_at
+            created_at, updated_at, COALESCE(gmail_message_id, ''), salary_period
         FROM jobs
         WHERE id = $1 AND user_id = $2
     `
@@ -89,6 +92,8 @@ func (r *JobRepository) GetByID(id int, userID int) (*models.Job, error) {
 		&job.InterviewDate,
 		&job.CreatedAt,
 		&job.UpdatedAt,
+		&job.GmailMessageID,
+		&job.SalaryPeriod,
 	)
 
 	if err == sql.ErrNoRows {
@@ -108,7 +113,7 @@ func (r *JobRepository) GetAllByUserID(userID int, filter *models.JobFilter) ([]
             id, user_id, company, position, location, job_type,
             salary_min, salary_max, currency, status, url,
             description, notes, applied_date, interview_date,
-            created_at, updated_at
+            created_at, updated_at, COALESCE(gmail_message_id, ''), salary_period
         FROM jobs
         WHERE user_id = $1
     `
@@ -171,6 +176,8 @@ func (r *JobRepository) GetAllByUserID(userID int, filter *models.JobFilter) ([]
 			&job.InterviewDate,
 			&job.CreatedAt,
 			&job.UpdatedAt,
+			&job.GmailMessageID,
+			&job.SalaryPeriod,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan job: %w", err)
@@ -187,8 +194,8 @@ func (r *JobRepository) Update(job *models.Job) error {
         SET company = $1, position = $2, location = $3, job_type = $4,
             salary_min = $5, salary_max = $6, currency = $7, status = $8,
             url = $9, description = $10, notes = $11, applied_date = $12,
-            interview_date = $13, updated_at = CURRENT_TIMESTAMP
-        WHERE id = $14 AND user_id = $15
+            interview_date = $13, salary_period = $14, updated_at = CURRENT_TIMESTAMP
+        WHERE id = $15 AND user_id = $16
         RETURNING updated_at
     `
 
@@ -207,6 +214,7 @@ func (r *JobRepository) Update(job *models.Job) error {
 		job.Notes,
 		job.AppliedDate,
 		job.InterviewDate,
+		job.SalaryPeriod,
 		job.ID,
 		job.UserID,
 	).Scan(&job.UpdatedAt)
@@ -262,12 +270,50 @@ func (r *JobRepository) GetStats(userID int) (map[string]int, error) {
 			continue
 		}
 		stats[status] = count
-		stats["total"] += count
+		// Recruiter leads are opportunities, not applications.
+		if status != "lead" {
+			stats["total"] += count
+		}
 	}
 
 	return stats, nil
 }
 
+// DeleteGmailImports removes every job that was created from a Gmail message.
+func (r *JobRepository) DeleteGmailImports(userID int) (int64, error) {
+	result, err := r.db.Exec(`
+        DELETE FROM jobs
+        WHERE user_id = $1 AND gmail_message_id IS NOT NULL AND gmail_message_id != ''
+    `, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete gmail imports: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// AnonymizeGmailImports keeps jobs created from Gmail but strips everything
+// that was copied out of the mailbox: the message id, the Gmail link, the
+// imported subject line, the linked source emails and contacts found in Gmail.
+func (r *JobRepository) AnonymizeGmailImports(userID int) (int64, error) {
+	result, err := r.db.Exec(`
+        WITH purged_emails AS (
+            DELETE FROM job_emails WHERE user_id = $1
+        ), purged_contacts AS (
+            DELETE FROM job_contacts WHERE user_id = $1 AND source = 'gmail'
+        )
+        UPDATE jobs
+        SET gmail_message_id = NULL,
+            url = CASE WHEN url LIKE 'https://mail.google.com/%' THEN '' ELSE url END,
+            notes = CASE WHEN notes LIKE '[Gmail Import]%' OR notes LIKE '[Imported from Gmail]%' THEN '' ELSE notes END,
+            updated_at = CURRENT_TIMESTAMP
+        WHERE user_id = $1 AND gmail_message_id IS NOT NULL AND gmail_message_id != ''
+    `, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to anonymize gmail imports: %w", err)
+	}
+	return result.RowsAffected()
+}
+
 // NEW: This function efficiently fetches only the Gmail message IDs for a user.
 // GetAllGmailMessageIDsByUserID efficiently fetches only the Gmail message IDs for a user.
 func (r *JobRepository) GetAllGmailMessageIDsByUserID(userID int) (map[string]struct{}, error) {
@@ -291,3 +337,35 @@ func (r *JobRepository) GetAllGmailMessageIDsByUserID(userID int) (map[string]st
 
 	return idSet, nil
 }
+
+// MarkFieldsEdited remembers that the user set these fields by hand, so
+// re-running the Gmail extractors never overwrites them.
+func (r *JobRepository) MarkFieldsEdited(jobID int, fields []string) error {
+	for _, f := range fields {
+		_, err := r.db.Exec(`
+            INSERT INTO job_field_edits (job_id, field)
+            VALUES ($1, $2)
+            ON CONFLICT (job_id, field) DO UPDATE SET edited_at = CURRENT_TIMESTAMP
+        `, jobID, f)
+		if err != nil {
+			return fmt.Errorf("failed to mark field edited: %w", err)
+		}
+	}
+	return nil
+}
+
+// FillSalary sets a job's pay range unless it already has one, so a range
+// the user typed in always wins over one read from a posting.
+func (r *JobRepository) FillSalary(jobID, userID, min, max int, currency, period string) (bool, error) {
+	result, err := r.db.Exec(`
+        UPDATE jobs
+        SET salary_min = $1, salary_max = $2, currency = $3, salary_period = $4,
+            updated_at = CURRENT_TIMESTAMP
+        WHERE id = $5 AND user_id = $6 AND salary_min IS NULL AND salary_max IS NULL
+    `, min, max, currency, period, jobID, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to fill salary: %w", err)
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
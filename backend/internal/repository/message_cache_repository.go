@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/gant123/jobTracker/internal/models"
+	"github.com/lib/pq"
+)
+
+// MessageCacheRepository keeps the headers of Gmail messages a user's scans
+// have already fetched, so later scans don't ask Gmail for them again.
+type MessageCacheRepository struct {
+	db *sql.DB
+}
+
+func NewMessageCacheRepository(db *sql.DB) *MessageCacheRepository {
+	return &MessageCacheRepository{db: db}
+}
+
+// GetMany returns the cached entries among ids, keyed by message id.
+func (r *MessageCacheRepository) GetMany(userID int, ids []string) (map[string]*models.CachedMessage, error) {
+	out := make(map[string]*models.CachedMessage, len(ids))
+	if len(ids) == 0 {
+		return out, nil
+	}
+	rows, err := r.db.Query(`
+        SELECT gmail_message_id, thread_id, subject, from_address, to_address, snippet,
+               message_date, classifier_version, class, events, fetched_at
+        FROM gmail_message_cache
+        WHERE user_id = $1 AND gmail_message_id = ANY($2)
+    `, userID, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read message cache: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		m := &models.CachedMessage{UserID: userID}
+		var date sql.NullTime
+		if err := rows.Scan(&m.GmailMessageID, &m.ThreadID, &m.Subject, &m.From, &m.To, &m.Snippet,
+			&date, &m.ClassifierVersion, &m.Class, &m.Events, &m.FetchedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan cached message: %w", err)
+		}
+		if date.Valid {
+			m.Date = date.Time
+		}
+		out[m.GmailMessageID] = m
+	}
+	return out, rows.Err()
+}
+
+// Put stores or refreshes a cache entry.
+func (r *MessageCacheRepository) Put(m *models.CachedMessage) error {
+	date := sql.NullTime{Time: m.Date, Valid: !m.Date.IsZero()}
+	events := m.Events
+	if len(events) == 0 {
+		events = []byte("[]")
+	}
+	_, err := r.db.Exec(`
+        INSERT INTO gmail_message_cache (
+            user_id, gmail_message_id, thread_id, subject, from_address, to_address, snippet,
+            message_date, classifier_version, class, events, fetched_at
+        ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, CURRENT_TIMESTAMP)
+        ON CONFLICT (user_id, gmail_message_id) DO UPDATE SET
+            thread_id = EXCLUDED.thread_id,
+            subject = EXCLUDED.subject,
+            from_address = EXCLUDED.from_address,
+            to_address = EXCLUDED.to_address,
+            snippet = EXCLUDED.snippet,
+            message_date = EXCLUDED.message_date,
+            classifier_version = EXCLUDED.classifier_version,
+            class = EXCLUDED.class,
+            events = EXCLUDED.events,
+            fetched_at = CURRENT_TIMESTAMP
+    `, m.UserID, m.GmailMessageID, m.ThreadID, m.Subject, m.From, m.To, m.Snippet,
+		date, m.ClassifierVersion, m.Class, []byte(events))
+	if err != nil {
+		return fmt.Errorf("failed to cache message: %w", err)
+	}
+	return nil
+}
+
+// Delete drops every cached message for a user.
+func (r *MessageCacheRepository) Delete(userID int) error {
+	if _, err := r.db.Exec(`DELETE FROM gmail_message_cache WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to clear message cache: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/gant123/jobTracker/internal/models"
+)
+
+// QuotaRepository persists daily Gmail API quota usage per user.
+type QuotaRepository struct {
+	db *sql.DB
+}
+
+func NewQuotaRepository(db *sql.DB) *QuotaRepository {
+	return &QuotaRepository{db: db}
+}
+
+// Add increments a user's counters for a day.
+func (r *QuotaRepository) Add(u *models.QuotaUsage) error {
+	_, err := r.db.Exec(`
+        INSERT INTO gmail_quota_usage (user_id, day, units, requests, throttled)
+        VALUES ($1, $2, $3, $4, $5)
+        ON CONFLICT (user_id, day) DO UPDATE SET
+            units = gmail_quota_usage.units + EXCLUDED.units,
+            requests = gmail_quota_usage.requests + EXCLUDED.requests,
+            throttled = gmail_quota_usage.throttled + EXCLUDED.throttled
+    `, u.UserID, u.Day, u.Units, u.Requests, u.Throttled)
+	if err != nil {
+		return fmt.Errorf("failed to record quota usage: %w", err)
+	}
+	return nil
+}
+
+// DayTotals returns what a user, and everyone together, has used on day.
+func (r *QuotaRepository) DayTotals(userID int, day time.Time) (user int64, global int64, err error) {
+	err = r.db.QueryRow(`
+        SELECT COALESCE(SUM(units) FILTER (WHERE user_id = $1), 0), COALESCE(SUM(units), 0)
+        FROM gmail_quota_usage
+        WHERE day = $2
+    `, userID, day).Scan(&user, &global)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read quota usage: %w", err)
+	}
+	return user, global, nil
+}
+
+// UserHistory returns a user's usage for the last days days, newest first.
+func (r *QuotaRepository) UserHistory(userID int, days int) ([]*models.QuotaUsage, error) {
+	rows, err := r.db.Query(`
+        SELECT user_id, day, units, requests, throttled
+        FROM gmail_quota_usage
+        WHERE user_id = $1 AND day > CURRENT_DATE - $2::int
+        ORDER BY day DESC
+    `, userID, days)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list quota usage: %w", err)
+	}
+	return scanQuotaUsage(rows)
+}
+
+// TopUsers returns the heaviest users on day.
+func (r *QuotaRepository) TopUsers(day time.Time, limit int) ([]*models.QuotaUsage, error) {
+	rows, err := r.db.Query(`
+        SELECT user_id, day, units, requests, throttled
+        FROM gmail_quota_usage
+        WHERE day = $1
+        ORDER BY units DESC
+        LIMIT $2
+    `, day, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list quota usage: %w", err)
+	}
+	return scanQuotaUsage(rows)
+}
+
+func scanQuotaUsage(rows *sql.Rows) ([]*models.QuotaUsage, error) {
+	defer rows.Close()
+	out := []*models.QuotaUsage{}
+	for rows.Next() {
+		u := &models.QuotaUsage{}
+		if err := rows.Scan(&u.UserID, &u.Day, &u.Units, &u.Requests, &u.Throttled); err != nil {
+			return nil, fmt.Errorf("failed to scan quota usage: %w", err)
+		}
+		out = append(out, u)
+	}
+	return out, rows.Err()
+}
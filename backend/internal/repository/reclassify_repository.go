@@ -0,0 +1,172 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/gant123/jobTracker/internal/models"
+)
+
+// Columns a proposal may change. Keys double as the allowlist that keeps
+// field names out of SQL.
+var reclassifyColumns = map[string]string{
+	"company":  "company",
+	"position": "position",
+	"location": "location",
+	"status":   "status",
+}
+
+// ReclassifyRepository stores the diffs produced by re-running the Gmail
+// extractors over past imports, and applies them.
+type ReclassifyRepository struct {
+	db *sql.DB
+}
+
+func NewReclassifyRepository(db *sql.DB) *ReclassifyRepository {
+	return &ReclassifyRepository{db: db}
+}
+
+// ListCandidates returns the user's jobs imported one-per-message from
+// Gmail. Digest leads are left out: their fields come from the message
+// body, not the metadata the extractors read.
+func (r *ReclassifyRepository) ListCandidates(userID int) ([]*models.ReclassifyCandidate, error) {
+	rows, err := r.db.Query(`
+        SELECT j.id, j.gmail_message_id, j.company, j.position, COALESCE(j.location, ''), j.status,
+               COALESCE((SELECT e.direction FROM job_emails e
+                         WHERE e.job_id = j.id AND e.gmail_message_id = j.gmail_message_id
+                         LIMIT 1), 'inbound'),
+               COALESCE((SELECT string_agg(f.field, ',') FROM job_field_edits f WHERE f.job_id = j.id), '')
+        FROM jobs j
+        WHERE j.user_id = $1
+          AND j.gmail_message_id IS NOT NULL AND j.gmail_message_id != ''
+          AND (j.source_key IS NULL OR j.source_key = '')
+        ORDER BY j.id
+    `, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reclassify candidates: %w", err)
+	}
+	defer rows.Close()
+
+	var out []*models.ReclassifyCandidate
+	for rows.Next() {
+		c := &models.ReclassifyCandidate{Edited: map[string]bool{}}
+		var edited string
+		if err := rows.Scan(&c.JobID, &c.GmailMessageID, &c.Company, &c.Position, &c.Location,
+			&c.Status, &c.Direction, &edited); err != nil {
+			return nil, fmt.Errorf("failed to scan reclassify candidate: %w", err)
+		}
+		for _, f := range strings.Split(edited, ",") {
+			if f != "" {
+				c.Edited[f] = true
+			}
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+// ReplaceProposals swaps the user's pending diff for a fresh one.
+func (r *ReclassifyRepository) ReplaceProposals(userID int, proposals []*models.ReclassifyProposal) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM reclassify_proposals WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to clear proposals: %w", err)
+	}
+	for _, p := range proposals {
+		err := tx.QueryRow(`
+            INSERT INTO reclassify_proposals (user_id, job_id, field, old_value, new_value)
+            VALUES ($1, $2, $3, $4, $5)
+            RETURNING id, created_at
+        `, userID, p.JobID, p.Field, p.OldValue, p.NewValue).Scan(&p.ID, &p.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to save proposal: %w", err)
+		}
+		p.UserID = userID
+	}
+	return tx.Commit()
+}
+
+func (r *ReclassifyRepository) ListProposals(userID int) ([]*models.ReclassifyProposal, error) {
+	rows, err := r.db.Query(`
+        SELECT id, user_id, job_id, field, old_value, new_value, created_at
+        FROM reclassify_proposals
+        WHERE user_id = $1
+        ORDER BY job_id, field
+    `, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list proposals: %w", err)
+	}
+	defer rows.Close()
+
+	proposals := []*models.ReclassifyProposal{}
+	for rows.Next() {
+		p := &models.ReclassifyProposal{}
+		if err := rows.Scan(&p.ID, &p.UserID, &p.JobID, &p.Field, &p.OldValue, &p.NewValue, &p.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan proposal: %w", err)
+		}
+		proposals = append(proposals, p)
+	}
+	return proposals, rows.Err()
+}
+
+// ApplyProposals writes the chosen proposals (all of them if ids is nil)
+// into their jobs and removes them from the diff. A proposal only lands if
+// its field still holds the value the diff was computed against and the
+// user hasn't edited it by hand since. It returns how many fields changed.
+func (r *ReclassifyRepository) ApplyProposals(userID int, ids []int) (int, error) {
+	proposals, err := r.ListProposals(userID)
+	if err != nil {
+		return 0, err
+	}
+	want := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		want[id] = true
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	applied := 0
+	for _, p := range proposals {
+		if ids != nil && !want[p.ID] {
+			continue
+		}
+		col, ok := reclassifyColumns[p.Field]
+		if !ok {
+			continue
+		}
+		res, err := tx.Exec(`
+            UPDATE jobs SET `+col+` = $1, updated_at = CURRENT_TIMESTAMP
+            WHERE id = $2 AND user_id = $3 AND COALESCE(`+col+`, '') = $4
+              AND NOT EXISTS (SELECT 1 FROM job_field_edits f WHERE f.job_id = $2 AND f.field = $5)
+        `, p.NewValue, p.JobID, userID, p.OldValue, p.Field)
+		if err != nil {
+			return 0, fmt.Errorf("failed to apply proposal: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			applied++
+		}
+		if _, err := tx.Exec(`DELETE FROM reclassify_proposals WHERE id = $1`, p.ID); err != nil {
+			return 0, fmt.Errorf("failed to remove proposal: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit proposals: %w", err)
+	}
+	return applied, nil
+}
+
+func (r *ReclassifyRepository) DeleteProposals(userID int) error {
+	if _, err := r.db.Exec(`DELETE FROM reclassify_proposals WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to discard proposals: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/gant123/jobTracker/internal/models"
+)
+
+// ScanFilterRepository stores what a user told us to keep out of (or in)
+// their Gmail scans: sender allow/block rules and dismissed messages.
+type ScanFilterRepository struct {
+	db *sql.DB
+}
+
+func NewScanFilterRepository(db *sql.DB) *ScanFilterRepository {
+	return &ScanFilterRepository{db: db}
+}
+
+func (r *ScanFilterRepository) ListRules(userID int) ([]*models.SenderRule, error) {
+	rows, err := r.db.Query(`
+        SELECT id, user_id, pattern, kind, created_at
+        FROM sender_rules
+        WHERE user_id = $1
+        ORDER BY kind, pattern
+    `, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sender rules: %w", err)
+	}
+	defer rows.Close()
+
+	rules := []*models.SenderRule{}
+	for rows.Next() {
+		rule := &models.SenderRule{}
+		if err := rows.Scan(&rule.ID, &rule.UserID, &rule.Pattern, &rule.Kind, &rule.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan sender rule: %w", err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+// SaveRule adds a rule, or flips the kind of an existing rule for the same
+// pattern.
+func (r *ScanFilterRepository) SaveRule(rule *models.SenderRule) error {
+	rule.Pattern = strings.ToLower(strings.TrimSpace(rule.Pattern))
+	err := r.db.QueryRow(`
+        INSERT INTO sender_rules (user_id, pattern, kind)
+        VALUES ($1, $2, $3)
+        ON CONFLICT (user_id, pattern) DO UPDATE SET kind = EXCLUDED.kind
+        RETURNING id, created_at
+    `, rule.UserID, rule.Pattern, rule.Kind).Scan(&rule.ID, &rule.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save sender rule: %w", err)
+	}
+	return nil
+}
+
+func (r *ScanFilterRepository) DeleteRule(id int, userID int) error {
+	result, err := r.db.Exec(`DELETE FROM sender_rules WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete sender rule: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("sender rule not found")
+	}
+	return nil
+}
+
+// DismissMessage remembers that a message is not about a job so scans
+// never offer or import it again.
+func (r *ScanFilterRepository) DismissMessage(userID int, messageID string, reason string) error {
+	_, err := r.db.Exec(`
+        INSERT INTO dismissed_messages (user_id, gmail_message_id, reason)
+        VALUES ($1, $2, $3)
+        ON CONFLICT (user_id, gmail_message_id) DO NOTHING
+    `, userID, messageID, reason)
+	if err != nil {
+		return fmt.Errorf("failed to dismiss message: %w", err)
+	}
+	return nil
+}
+
+// DeleteDismissed forgets every message a user dismissed.
+func (r *ScanFilterRepository) DeleteDismissed(userID int) error {
+	if _, err := r.db.Exec(`DELETE FROM dismissed_messages WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to delete dismissed messages: %w", err)
+	}
+	return nil
+}
+
+func (r *ScanFilterRepository) DismissedMessageIDs(userID int) (map[string]struct{}, error) {
+	rows, err := r.db.Query(`SELECT gmail_message_id FROM dismissed_messages WHERE user_id = $1`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query dismissed messages: %w", err)
+	}
+	defer rows.Close()
+
+	idSet := make(map[string]struct{})
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan dismissed message: %w", err)
+		}
+		idSet[id] = struct{}{}
+	}
+	return idSet, rows.Err()
+}
+
+// Languages returns the phrase packs a user's scans include, or nil if they
+// never chose any.
+func (r *ScanFilterRepository) Languages(userID int) ([]string, error) {
+	var langs string
+	err := r.db.QueryRow(`SELECT languages FROM scan_settings WHERE user_id = $1`, userID).Scan(&langs)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get scan languages: %w", err)
+	}
+	return strings.Split(langs, ","), nil
+}
+
+func (r *ScanFilterRepository) SaveLanguages(userID int, langs []string) error {
+	_, err := r.db.Exec(`
+        INSERT INTO scan_settings (user_id, languages, updated_at)
+        VALUES ($1, $2, CURRENT_TIMESTAMP)
+        ON CONFLICT (user_id) DO UPDATE SET languages = EXCLUDED.languages, updated_at = CURRENT_TIMESTAMP
+    `, userID, strings.Join(langs, ","))
+	if err != nil {
+		return fmt.Errorf("failed to save scan languages: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,252 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gant123/jobTracker/internal/models"
+)
+
+// schedulerLock is the advisory lock that makes one instance at a time the
+// scheduler ("jobsched").
+const schedulerLock = 0x6a6f627363686564
+
+// userScheduleLock is the advisory lock space ("js") serializing schedule
+// creation per user; the second key is the user id.
+const userScheduleLock = 0x6a73
+
+var (
+	// ErrScheduleNotFound means the user has no schedule with that id.
+	ErrScheduleNotFound = errors.New("schedule not found")
+	// ErrScheduleLimit means the user already has as many schedules as
+	// they may.
+	ErrScheduleLimit = errors.New("schedule limit reached")
+)
+
+type ScheduleRepository struct {
+	db *sql.DB
+}
+
+func NewScheduleRepository(db *sql.DB) *ScheduleRepository {
+	return &ScheduleRepository{db: db}
+}
+
+// SaveSystem creates or updates the system schedule for a job type. A
+// changed cron or timezone takes effect from next; otherwise the planned
+// run stands.
+func (r *ScheduleRepository) SaveSystem(s *models.JobSchedule) error {
+	return r.db.QueryRow(`
+        INSERT INTO job_schedules (user_id, job_type, payload, cron, timezone, next_run_at)
+        VALUES (NULL, $1, $2, $3, $4, $5)
+        ON CONFLICT (job_type) WHERE user_id IS NULL DO UPDATE SET
+            payload = EXCLUDED.payload,
+            cron = EXCLUDED.cron,
+            timezone = EXCLUDED.timezone,
+            next_run_at = CASE
+                WHEN job_schedules.cron <> EXCLUDED.cron OR job_schedules.timezone <> EXCLUDED.timezone
+                THEN EXCLUDED.next_run_at ELSE job_schedules.next_run_at END,
+            updated_at = NOW()
+        RETURNING id, enabled, next_run_at, created_at
+    `, s.JobType, payloadJSON(s.Payload), s.Cron, s.Timezone, s.NextRunAt.UTC()).Scan(
+		&s.ID, &s.Enabled, &s.NextRunAt, &s.CreatedAt,
+	)
+}
+
+// Create saves a user's schedule unless they already have limit of them,
+// in which case it returns ErrScheduleLimit. Concurrent creates for one
+// user take turns, so they can't both slip in under the limit.
+func (r *ScheduleRepository) Create(s *models.JobSchedule, limit int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1, $2)`, userScheduleLock, *s.UserID); err != nil {
+		return err
+	}
+	var n int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM job_schedules WHERE user_id = $1`, *s.UserID).Scan(&n); err != nil {
+		return err
+	}
+	if n >= limit {
+		return ErrScheduleLimit
+	}
+
+	s.Enabled = true
+	if err := tx.QueryRow(`
+        INSERT INTO job_schedules (user_id, job_type, payload, cron, timezone, next_run_at)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        RETURNING id, created_at
+    `, s.UserID, s.JobType, payloadJSON(s.Payload), s.Cron, s.Timezone, s.NextRunAt.UTC()).Scan(
+		&s.ID, &s.CreatedAt,
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *ScheduleRepository) GetByUserID(userID int) ([]models.JobSchedule, error) {
+	rows, err := r.db.Query(`
+        SELECT id, user_id, job_type, payload, cron, timezone, enabled, next_run_at, last_run_at, created_at
+        FROM job_schedules
+        WHERE user_id = $1
+        ORDER BY created_at
+    `, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list schedules: %w", err)
+	}
+	defer rows.Close()
+
+	schedules := []models.JobSchedule{}
+	for rows.Next() {
+		var s models.JobSchedule
+		var uid sql.NullInt64
+		if err := rows.Scan(&s.ID, &uid, &s.JobType, &s.Payload, &s.Cron, &s.Timezone,
+			&s.Enabled, &s.NextRunAt, &s.LastRunAt, &s.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan schedule: %w", err)
+		}
+		if uid.Valid {
+			id := int(uid.Int64)
+			s.UserID = &id
+		}
+		schedules = append(schedules, s)
+	}
+	return schedules, rows.Err()
+}
+
+func (r *ScheduleRepository) Delete(id int, userID int) error {
+	result, err := r.db.Exec(`DELETE FROM job_schedules WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete schedule: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrScheduleNotFound
+	}
+	return nil
+}
+
+// DeleteUserSchedules drops a user's schedules of job types starting with
+// typePrefix (e.g. "gmail_").
+func (r *ScheduleRepository) DeleteUserSchedules(userID int, typePrefix string) error {
+	_, err := r.db.Exec(`
+        DELETE FROM job_schedules WHERE user_id = $1 AND job_type LIKE $2 || '%'
+    `, userID, typePrefix)
+	if err != nil {
+		return fmt.Errorf("failed to delete schedules: %w", err)
+	}
+	return nil
+}
+
+// ScheduledJob is how a schedule's job is queued.
+type ScheduledJob struct {
+	MaxAttempts int
+	Priority    Priority
+}
+
+// FireDue queues a job for every schedule due at now and moves each one on
+// to the run plan returns, along with how to queue its job. A zero
+// next run disables the schedule. A schedule whose previous job is still
+// waiting or running is moved on without queueing another.
+//
+// Only one instance fires at a time: the others don't get the advisory
+// lock and report leader false.
+func (r *ScheduleRepository) FireDue(now time.Time, plan func(s *models.JobSchedule) (next time.Time, job ScheduledJob)) (fired int, leader bool, err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, false, err
+	}
+	defer tx.Rollback()
+
+	if err := tx.QueryRow(`SELECT pg_try_advisory_xact_lock($1)`, int64(schedulerLock)).Scan(&leader); err != nil || !leader {
+		return 0, false, err
+	}
+
+	rows, err := tx.Query(`
+        SELECT id, user_id, job_type, payload, cron, timezone, next_run_at
+        FROM job_schedules
+        WHERE enabled AND next_run_at <= $1
+        ORDER BY next_run_at
+        LIMIT 500
+    `, now.UTC())
+	if err != nil {
+		return 0, true, err
+	}
+	var due []models.JobSchedule
+	for rows.Next() {
+		var s models.JobSchedule
+		var uid sql.NullInt64
+		if err := rows.Scan(&s.ID, &uid, &s.JobType, &s.Payload, &s.Cron, &s.Timezone, &s.NextRunAt); err != nil {
+			rows.Close()
+			return 0, true, err
+		}
+		if uid.Valid {
+			id := int(uid.Int64)
+			s.UserID = &id
+		}
+		due = append(due, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, true, err
+	}
+
+	for i := range due {
+		s := &due[i]
+		next, job := plan(s)
+		res, err := tx.Exec(`
+            INSERT INTO background_jobs (type, user_id, payload, status, process_after, max_attempts, unique_key, priority)
+            SELECT $1::varchar, $2::int, $3::jsonb, 'pending', NOW(), $4::int, '', $5::smallint
+            WHERE NOT EXISTS (
+                SELECT 1 FROM background_jobs
+                WHERE type = $1::varchar AND user_id IS NOT DISTINCT FROM $2::int
+                AND status IN ('pending', 'processing')
+            )
+            ON CONFLICT (type, COALESCE(user_id, 0), unique_key)
+                WHERE unique_key IS NOT NULL AND status IN ('pending', 'processing')
+                DO NOTHING
+        `, s.JobType, s.UserID, payloadJSON(s.Payload), max(job.MaxAttempts, 1), job.Priority)
+		if err != nil {
+			return 0, true, fmt.Errorf("failed to queue scheduled %s: %w", s.JobType, err)
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			fired++
+		}
+
+		if next.IsZero() {
+			_, err = tx.Exec(`
+                UPDATE job_schedules SET enabled = FALSE, last_run_at = $2, updated_at = NOW()
+                WHERE id = $1
+            `, s.ID, now.UTC())
+		} else {
+			_, err = tx.Exec(`
+                UPDATE job_schedules SET next_run_at = $2, last_run_at = $3, updated_at = NOW()
+                WHERE id = $1
+            `, s.ID, next.UTC(), now.UTC())
+		}
+		if err != nil {
+			return 0, true, fmt.Errorf("failed to advance schedule %d: %w", s.ID, err)
+		}
+	}
+	if fired > 0 {
+		// Delivered when the transaction commits.
+		if _, err := tx.Exec(`SELECT pg_notify($1, '')`, JobsChannel); err != nil {
+			return 0, true, err
+		}
+	}
+	return fired, true, tx.Commit()
+}
+
+func payloadJSON(p json.RawMessage) []byte {
+	if len(p) == 0 {
+		return []byte("{}")
+	}
+	return p
+}
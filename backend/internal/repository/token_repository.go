@@ -15,6 +15,8 @@ type TokenRepository interface {
 	Save(ctx context.Context, userID int, provider string, tok *oauth2.Token) error
 	Get(ctx context.Context, userID int, provider string) (*oauth2.Token, error)
 	Delete(ctx context.Context, userID int, provider string) error
+	MarkNeedsReauth(ctx context.Context, userID int, provider string, reason string) error
+	NeedsReauth(ctx context.Context, userID int, provider string) (bool, error)
 }
 
 type PostgresTokenRepository struct {
@@ -47,7 +49,7 @@ func (r *PostgresTokenRepository) Save(ctx context.Context, userID int, provider
 		}
 	}
 
-	// upsert
+	// upsert; a freshly saved token is by definition usable again
 	_, err = r.db.ExecContext(ctx, `
 		INSERT INTO email_tokens (user_id, provider, access_token_enc, refresh_token_enc, expiry, created_at, updated_at)
 		VALUES ($1, $2, $3, $4, $5, NOW(), NOW())
@@ -55,6 +57,8 @@ func (r *PostgresTokenRepository) Save(ctx context.Context, userID int, provider
 		DO UPDATE SET access_token_enc=EXCLUDED.access_token_enc,
 		              refresh_token_enc=EXCLUDED.refresh_token_enc,
 		              expiry=EXCLUDED.expiry,
+		              needs_reauth=FALSE,
+		              reauth_reason=NULL,
 		              updated_at=NOW()
 	`, userID, provider, encAccess, encRefresh, nullableTime(tok.Expiry))
 	return err
@@ -104,6 +108,25 @@ func (r *PostgresTokenRepository) Delete(ctx context.Context, userID int, provid
 	return err
 }
 
+// MarkNeedsReauth flags the stored token as unusable (e.g. Google answered
+// invalid_grant). The row is kept so status can tell the user to reconnect.
+func (r *PostgresTokenRepository) MarkNeedsReauth(ctx context.Context, userID int, provider string, reason string) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE email_tokens
+		SET needs_reauth=TRUE, reauth_reason=$3, updated_at=NOW()
+		WHERE user_id=$1 AND provider=$2
+	`, userID, provider, reason)
+	return err
+}
+
+func (r *PostgresTokenRepository) NeedsReauth(ctx context.Context, userID int, provider string) (bool, error) {
+	var needs bool
+	err := r.db.QueryRowContext(ctx, `
+		SELECT needs_reauth FROM email_tokens WHERE user_id=$1 AND provider=$2
+	`, userID, provider).Scan(&needs)
+	return needs, err
+}
+
 func nullableTime(t time.Time) *time.Time {
 	if t.IsZero() {
 		return nil
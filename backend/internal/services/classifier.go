@@ -0,0 +1,302 @@
+package services
+
+import (
+	"encoding/json"
+	"math"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ---------- Per-user learning classifier ----------
+
+// ClassifierVersion changes whenever feature extraction changes, so models
+// trained on old features are retrained instead of silently misread.
+const ClassifierVersion = 1
+
+// Statuses the scanner itself assigns. Moves into other statuses
+// (interviewing, offer, withdrawn) are the application progressing, not
+// the scanner having been wrong, so they are never used as labels.
+var ClassifiedStatuses = map[string]bool{
+	"lead": true, "wishlist": true, "applied": true, "rejected": true,
+}
+
+const (
+	// Below this many examples the model only watches.
+	minModelExamples = 5
+	// Examples a label needs before the model may predict it over the
+	// rules. At least two labels must have them.
+	minLabelExamples = 3
+	// Leave-one-out accuracy the model needs before it overrides the rules.
+	minModelAccuracy = 0.75
+	// Posterior needed before the model overrides the regex rules.
+	minModelConfidence = 0.8
+	// Confirmed imports trained on besides the corrections.
+	maxImportExamples = 1000
+)
+
+// NaiveBayes is a multinomial naive Bayes model over email tokens that
+// predicts a status, plus a sender -> company map learned from company
+// corrections (see companyKey).
+type NaiveBayes struct {
+	Version   int                       `json:"version"`
+	Labels    map[string]int            `json:"labels"` // label -> documents
+	Tokens    map[string]map[string]int `json:"tokens"` // label -> token -> count
+	Totals    map[string]int            `json:"totals"` // label -> tokens
+	Companies map[string]string         `json:"companies,omitempty"`
+	Examples  int                       `json:"examples"`
+	// Leave-one-out accuracy on the status examples.
+	Accuracy  float64   `json:"accuracy"`
+	TrainedAt time.Time `json:"trained_at"`
+}
+
+// TrainingExample is one user correction of an imported job, or an import
+// whose status the user let stand.
+type TrainingExample struct {
+	Subject string
+	Snippet string
+	From    string
+	Field   string // status|company
+	Value   string // what the user changed it to
+}
+
+func NewNaiveBayes() *NaiveBayes {
+	return &NaiveBayes{
+		Version:   ClassifierVersion,
+		Labels:    map[string]int{},
+		Tokens:    map[string]map[string]int{},
+		Totals:    map[string]int{},
+		Companies: map[string]string{},
+	}
+}
+
+// TrainClassifier builds a user's model from their corrections and the
+// imports they confirmed by leaving them alone. Without the latter it would
+// only ever see what the rules got wrong.
+func TrainClassifier(examples []TrainingExample) *NaiveBayes {
+	nb := NewNaiveBayes()
+	var statusExamples [][]string
+	var statusLabels []string
+	for _, ex := range examples {
+		switch ex.Field {
+		case "status":
+			if !ClassifiedStatuses[ex.Value] {
+				continue
+			}
+			f := EmailFeatures(ex.Subject, ex.Snippet, ex.From)
+			nb.Train(f, ex.Value)
+			statusExamples = append(statusExamples, f)
+			statusLabels = append(statusLabels, ex.Value)
+		case "company":
+			if k := companyKey(ex.From); k != "" && ex.Value != "" {
+				nb.Companies[k] = ex.Value
+			}
+		}
+	}
+	nb.Accuracy = nb.leaveOneOut(statusExamples, statusLabels)
+	nb.TrainedAt = time.Now().UTC()
+	return nb
+}
+
+func (nb *NaiveBayes) Train(features []string, label string) {
+	nb.Labels[label]++
+	nb.Examples++
+	if nb.Tokens[label] == nil {
+		nb.Tokens[label] = map[string]int{}
+	}
+	for _, f := range features {
+		nb.Tokens[label][f]++
+		nb.Totals[label]++
+	}
+}
+
+func (nb *NaiveBayes) untrain(features []string, label string) {
+	nb.Labels[label]--
+	nb.Examples--
+	for _, f := range features {
+		nb.Tokens[label][f]--
+		nb.Totals[label]--
+	}
+}
+
+// Predict returns the most likely label and its posterior probability.
+func (nb *NaiveBayes) Predict(features []string) (string, float64) {
+	if nb == nil || nb.Examples == 0 {
+		return "", 0
+	}
+	vocab := map[string]struct{}{}
+	for _, toks := range nb.Tokens {
+		for t, c := range toks {
+			if c > 0 {
+				vocab[t] = struct{}{}
+			}
+		}
+	}
+	v := float64(len(vocab) + 1)
+
+	scores := map[string]float64{}
+	best, bestScore := "", math.Inf(-1)
+	for label, docs := range nb.Labels {
+		if docs <= 0 {
+			continue
+		}
+		score := math.Log(float64(docs) / float64(nb.Examples))
+		total := float64(nb.Totals[label])
+		for _, f := range features {
+			score += math.Log((float64(nb.Tokens[label][f]) + 1) / (total + v))
+		}
+		scores[label] = score
+		if score > bestScore {
+			best, bestScore = label, score
+		}
+	}
+	if best == "" {
+		return "", 0
+	}
+	// Softmax over log scores for a comparable confidence.
+	var sum float64
+	for _, s := range scores {
+		sum += math.Exp(s - bestScore)
+	}
+	return best, 1 / sum
+}
+
+func (nb *NaiveBayes) leaveOneOut(features [][]string, labels []string) float64 {
+	if len(features) < 2 {
+		return 0
+	}
+	correct := 0
+	for i := range features {
+		nb.untrain(features[i], labels[i])
+		if got, _ := nb.Predict(features[i]); got == labels[i] {
+			correct++
+		}
+		nb.Train(features[i], labels[i])
+	}
+	return float64(correct) / float64(len(features))
+}
+
+// Ready reports whether the model has seen enough to be trusted: enough
+// examples of at least two labels, and good enough at predicting them.
+// A model that only knows one label would call everything that.
+func (nb *NaiveBayes) Ready() bool {
+	if nb == nil || nb.Version != ClassifierVersion || nb.Examples < minModelExamples {
+		return false
+	}
+	labels := 0
+	for label := range nb.Labels {
+		if nb.knows(label) {
+			labels++
+		}
+	}
+	return labels >= 2 && nb.Accuracy >= minModelAccuracy
+}
+
+// knows reports whether label has enough examples to be predicted.
+func (nb *NaiveBayes) knows(label string) bool {
+	return nb.Labels[label] >= minLabelExamples
+}
+
+func DecodeNaiveBayes(data []byte) (*NaiveBayes, error) {
+	nb := NewNaiveBayes()
+	if err := json.Unmarshal(data, nb); err != nil {
+		return nil, err
+	}
+	return nb, nil
+}
+
+// ---------- Features ----------
+
+var reToken = regexp.MustCompile(`[\p{L}\p{N}]+`)
+
+// EmailFeatures tokenizes subject, snippet and sender into prefixed
+// features so "offer" in a subject and in a body count separately.
+func EmailFeatures(subject, snippet, from string) []string {
+	var out []string
+	for _, t := range reToken.FindAllString(strings.ToLower(subject), -1) {
+		if len(t) > 1 {
+			out = append(out, "s:"+t)
+		}
+	}
+	for _, t := range reToken.FindAllString(strings.ToLower(snippet), -1) {
+		if len(t) > 2 {
+			out = append(out, "b:"+t)
+		}
+	}
+	if d := senderDomain(from); d != "" {
+		out = append(out, "d:"+d)
+		if i := strings.Index(d, "."); i != -1 {
+			out = append(out, "d:"+d[i+1:])
+		}
+	}
+	return out
+}
+
+func senderDomain(from string) string {
+	addr := from
+	if a, err := mail.ParseAddress(from); err == nil {
+		addr = a.Address
+	}
+	i := strings.LastIndex(addr, "@")
+	if i == -1 {
+		return ""
+	}
+	return strings.ToLower(strings.Trim(addr[i+1:], " >"))
+}
+
+// Applicant tracking systems and job boards mail on behalf of many
+// employers, so their domains say nothing about the company.
+var sharedSenderDomains = []string{
+	"greenhouse.io", "greenhouse-mail.io", "lever.co", "workday.com", "myworkday.com",
+	"myworkdayjobs.com", "smartrecruiters.com", "ashbyhq.com", "workable.com",
+	"workablemail.com", "icims.com", "jobvite.com", "taleo.net", "successfactors.com",
+	"bamboohr.com", "recruitee.com", "breezy.hr", "applytojob.com", "linkedin.com",
+	"indeed.com", "indeedemail.com", "glassdoor.com", "ziprecruiter.com", "monster.com",
+	"dice.com", "wellfound.com", "hired.com",
+}
+
+// companyKey is what a company correction is remembered under: the sender's
+// domain for a company's own mail, the whole address for a personal mailbox,
+// and nothing for an ATS or job board.
+func companyKey(from string) string {
+	d := senderDomain(from)
+	if d == "" {
+		return ""
+	}
+	for _, s := range sharedSenderDomains {
+		if d == s || strings.HasSuffix(d, "."+s) {
+			return ""
+		}
+	}
+	if freeMailDomains[d] {
+		addr := from
+		if a, err := mail.ParseAddress(from); err == nil {
+			addr = a.Address
+		}
+		return strings.ToLower(strings.Trim(addr, " <>"))
+	}
+	return d
+}
+
+// ---------- Second opinion ----------
+
+// applyModel lets a trained user model overrule the regex rules when it is
+// confident, and records what it thought either way.
+func applyModel(nb *NaiveBayes, ev *EmailJobEvent) {
+	if !nb.Ready() {
+		return
+	}
+	if c, ok := nb.Companies[companyKey(ev.From)]; ok {
+		ev.Company = c
+	}
+	label, p := nb.Predict(EmailFeatures(ev.Subject, ev.Snippet, ev.From))
+	if label == "" {
+		return
+	}
+	ev.ModelStatus = label
+	ev.ModelConfidence = p
+	if label != ev.Status && p >= minModelConfidence && nb.knows(label) {
+		ev.Status = label
+	}
+}
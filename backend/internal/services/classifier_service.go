@@ -0,0 +1,160 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/gant123/jobTracker/internal/jobs"
+	"github.com/gant123/jobTracker/internal/models"
+	"github.com/gant123/jobTracker/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// TrainClassifierJob retrains a user's model from their corrections.
+var TrainClassifierJob = jobs.Type[jobs.None]{Name: "classifier_train"}
+
+type ClassifierService struct {
+	repo     *repository.ClassifierRepository
+	jobQueue *repository.JobQueueRepository
+}
+
+func NewClassifierService(repo *repository.ClassifierRepository, jobQueue *repository.JobQueueRepository) *ClassifierService {
+	return &ClassifierService{repo: repo, jobQueue: jobQueue}
+}
+
+// RecordCorrections compares a Gmail-imported job before and after a user
+// edit and stores the status and company changes the model can learn from.
+// Positions differ from one email to the next even for the same sender, so
+// they aren't recorded. source is the email the job was imported from.
+// Training is queued when anything was recorded.
+func (s *ClassifierService) RecordCorrections(before, after *models.Job, source *models.JobEmail) error {
+	if before.GmailMessageID == "" || source == nil {
+		return nil
+	}
+
+	var changes []*models.ClassifierCorrection
+	add := func(field, oldValue, newValue string) {
+		if oldValue == newValue {
+			return
+		}
+		jobID := after.ID
+		changes = append(changes, &models.ClassifierCorrection{
+			UserID:         after.UserID,
+			JobID:          &jobID,
+			GmailMessageID: before.GmailMessageID,
+			Field:          field,
+			OldValue:       oldValue,
+			NewValue:       newValue,
+			Subject:        source.Subject,
+			Snippet:        source.Snippet,
+			From:           source.From,
+		})
+	}
+	if ClassifiedStatuses[before.Status] && ClassifiedStatuses[after.Status] {
+		add("status", before.Status, after.Status)
+	}
+	add("company", before.Company, after.Company)
+
+	for _, c := range changes {
+		if err := s.repo.RecordCorrection(c); err != nil {
+			return err
+		}
+	}
+	if len(changes) > 0 {
+		return s.QueueTraining(after.UserID)
+	}
+	return nil
+}
+
+// QueueTraining enqueues a retrain unless one is already waiting or
+// running.
+func (s *ClassifierService) QueueTraining(userID int) error {
+	_, err := TrainClassifierJob.EnqueueUnique(s.jobQueue, userID, "", jobs.None{})
+	return err
+}
+
+// RunTraining is the TrainClassifierJob handler.
+func (s *ClassifierService) RunTraining(ctx context.Context, log *logrus.Entry, job *jobs.Job, _ jobs.None) error {
+	nb, err := s.Train(job.UserID)
+	if err != nil {
+		return fmt.Errorf("training failed: %w", err)
+	}
+	log.WithFields(logrus.Fields{
+		"examples": nb.Examples,
+		"accuracy": nb.Accuracy,
+	}).Info("Classifier trained")
+	return nil
+}
+
+// Train rebuilds and stores a user's model.
+func (s *ClassifierService) Train(userID int) (*NaiveBayes, error) {
+	corrections, err := s.repo.ListCorrections(userID)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]string, 0, len(ClassifiedStatuses))
+	for st := range ClassifiedStatuses {
+		statuses = append(statuses, st)
+	}
+	confirmed, err := s.repo.ListConfirmedImports(userID, statuses, maxImportExamples)
+	if err != nil {
+		return nil, err
+	}
+	examples := make([]TrainingExample, 0, len(corrections)+len(confirmed))
+	for _, c := range corrections {
+		examples = append(examples, TrainingExample{
+			Subject: c.Subject,
+			Snippet: c.Snippet,
+			From:    c.From,
+			Field:   c.Field,
+			Value:   c.NewValue,
+		})
+	}
+	for _, c := range confirmed {
+		examples = append(examples, TrainingExample{
+			Subject: c.Subject,
+			Snippet: c.Snippet,
+			From:    c.From,
+			Field:   "status",
+			Value:   c.Status,
+		})
+	}
+
+	nb := TrainClassifier(examples)
+	data, err := json.Marshal(nb)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.SaveModel(userID, nb.Version, nb.Examples, nb.Accuracy, data); err != nil {
+		return nil, fmt.Errorf("failed to save model: %w", err)
+	}
+	return nb, nil
+}
+
+// Model loads a user's trained model; nil if they have none yet.
+func (s *ClassifierService) Model(userID int) (*NaiveBayes, error) {
+	data, err := s.repo.GetModel(userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return DecodeNaiveBayes(data)
+}
+
+// Forget drops everything the user's model learned from their mail: the
+// corrections, the model and any retrain still queued.
+func (s *ClassifierService) Forget(userID int) error {
+	if err := s.jobQueue.DeleteUserJobs(userID, TrainClassifierJob.Name); err != nil {
+		return err
+	}
+	return s.repo.DeleteUserData(userID)
+}
+
+func (s *ClassifierService) Info(userID int) (*models.ClassifierInfo, error) {
+	return s.repo.GetInfo(userID)
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+)
+
+func statusExamples(label, subject, from string, n int) []TrainingExample {
+	out := make([]TrainingExample, n)
+	for i := range out {
+		out[i] = TrainingExample{
+			Subject: fmt.Sprintf("%s %d", subject, i),
+			Snippet: subject,
+			From:    from,
+			Field:   "status",
+			Value:   label,
+		}
+	}
+	return out
+}
+
+func TestSingleLabelModelNeverOverrides(t *testing.T) {
+	nb := TrainClassifier(statusExamples("rejected", "Update on your application", "jobs@acme.com", 5))
+	if nb.Ready() {
+		t.Fatalf("model with one label is ready (accuracy %.2f)", nb.Accuracy)
+	}
+
+	ev := &EmailJobEvent{Subject: "Thanks for applying to Acme", From: "jobs@acme.com", Status: "applied"}
+	applyModel(nb, ev)
+	if ev.Status != "applied" {
+		t.Errorf("status = %q, want the rules' %q", ev.Status, "applied")
+	}
+}
+
+func TestModelNeedsExamplesOfEachLabel(t *testing.T) {
+	examples := statusExamples("rejected", "Unfortunately we decided not to move forward", "jobs@acme.com", 6)
+	examples = append(examples, statusExamples("applied", "Thank you for applying", "noreply@greenhouse.io", 2)...)
+	nb := TrainClassifier(examples)
+	if nb.Ready() {
+		t.Fatal("model with two examples of its second label is ready")
+	}
+
+	examples = append(examples, statusExamples("applied", "Thank you for applying", "noreply@greenhouse.io", 4)...)
+	nb = TrainClassifier(examples)
+	if !nb.Ready() {
+		t.Fatalf("model with enough examples of two labels isn't ready (accuracy %.2f)", nb.Accuracy)
+	}
+	ev := &EmailJobEvent{Subject: "Unfortunately we decided not to move forward", From: "jobs@acme.com", Status: "applied"}
+	applyModel(nb, ev)
+	if ev.Status != "rejected" {
+		t.Errorf("status = %q, want the model's %q", ev.Status, "rejected")
+	}
+}
+
+func TestCompanyCorrectionsSkipSharedSenders(t *testing.T) {
+	examples := statusExamples("rejected", "Unfortunately we decided not to move forward", "jobs@acme.com", 6)
+	examples = append(examples, statusExamples("applied", "Thank you for applying", "jobs@acme.com", 6)...)
+	examples = append(examples,
+		TrainingExample{From: "Acme Careers <careers@acme.com>", Field: "company", Value: "Acme Corp"},
+		TrainingExample{From: "no-reply@us.greenhouse-mail.io", Field: "company", Value: "Globex"},
+		TrainingExample{From: "Initech via Lever <no-reply@hire.lever.co>", Field: "company", Value: "Initech"},
+		TrainingExample{From: "jobs-noreply@linkedin.com", Field: "company", Value: "Hooli"},
+		TrainingExample{From: "Jane Recruiter <Jane.Doe@gmail.com>", Field: "company", Value: "Umbrella"},
+	)
+	nb := TrainClassifier(examples)
+	if !nb.Ready() {
+		t.Fatalf("model isn't ready (accuracy %.2f)", nb.Accuracy)
+	}
+
+	tests := []struct {
+		from, company, want string
+	}{
+		{"jobs@acme.com", "Acme", "Acme Corp"},
+		{"no-reply@us.greenhouse-mail.io", "Soylent", "Soylent"},
+		{"no-reply@hire.lever.co", "Soylent", "Soylent"},
+		{"jobs-noreply@linkedin.com", "Soylent", "Soylent"},
+		{"jane.doe@gmail.com", "Umbrella Inc", "Umbrella"},
+		{"someone.else@gmail.com", "Soylent", "Soylent"},
+	}
+	for _, tt := range tests {
+		ev := &EmailJobEvent{Subject: "Thank you for applying", From: tt.from, Company: tt.company, Status: "applied"}
+		applyModel(nb, ev)
+		if ev.Company != tt.want {
+			t.Errorf("from %s: company = %q, want %q", tt.from, ev.Company, tt.want)
+		}
+	}
+}
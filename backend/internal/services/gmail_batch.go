@@ -0,0 +1,325 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	gmail "google.golang.org/api/gmail/v1"
+	"google.golang.org/api/googleapi"
+)
+
+// ---------- Message fetching ----------
+
+// MessageFetcher downloads the metadata of many messages at once.
+type MessageFetcher interface {
+	// Metadata returns the messages it could fetch and, per message id,
+	// why the others failed. The error is for failures that doom the whole
+	// call, such as a revoked grant, spent quota or a cancelled context.
+	Metadata(ctx context.Context, ids, headers []string) (*FetchResult, error)
+}
+
+type FetchResult struct {
+	Messages map[string]*gmail.Message
+	Errors   map[string]error
+}
+
+func newFetchResult() *FetchResult {
+	return &FetchResult{Messages: map[string]*gmail.Message{}, Errors: map[string]error{}}
+}
+
+// NewMessageFetcher picks how to talk to Gmail: batched requests by
+// default, one request per message if GMAIL_BATCH=off. client must be the
+// authorized client srv was built with.
+func NewMessageFetcher(client *http.Client, srv *gmail.Service) MessageFetcher {
+	parallel := &ParallelFetcher{srv: srv}
+	if strings.EqualFold(os.Getenv("GMAIL_BATCH"), "off") {
+		return parallel
+	}
+	return &BatchFetcher{client: client, endpoint: gmailBatchEndpoint, fallback: parallel}
+}
+
+// ---------- Parallel ----------
+
+// ParallelFetcher issues one Messages.Get per id, at most 16 at a time;
+// the quota transport narrows that further when Gmail pushes back.
+type ParallelFetcher struct {
+	srv *gmail.Service
+}
+
+func (f *ParallelFetcher) Metadata(ctx context.Context, ids, headers []string) (*FetchResult, error) {
+	out := newFetchResult()
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, 16)
+
+	for _, id := range ids {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			msg, err := f.srv.Users.Messages.Get("me", id).
+				Format("metadata").
+				MetadataHeaders(headers...).
+				Context(ctx).
+				Do()
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				out.Errors[id] = err
+				return
+			}
+			out.Messages[id] = msg
+		}(id)
+	}
+	wg.Wait()
+
+	for _, err := range out.Errors {
+		if fatalFetchError(err) {
+			return out, err
+		}
+	}
+	return out, ctx.Err()
+}
+
+// fatalFetchError reports whether err fails every other request of the
+// call too, so it must reach the job instead of being filed per message.
+func fatalFetchError(err error) bool {
+	var qerr *QuotaExhaustedError
+	return errors.Is(err, ErrGrantRevoked) || errors.As(err, &qerr)
+}
+
+// ---------- Batch ----------
+
+const (
+	gmailBatchEndpoint = "https://gmail.googleapis.com/batch/gmail/v1"
+	// Gmail accepts up to 100 sub-requests per batch.
+	maxBatchParts = 100
+	// Attempts per message for parts that failed with a retryable status.
+	maxPartAttempts = 4
+)
+
+// BatchFetcher packs metadata requests into Gmail's multipart batch
+// endpoint. Parts that fail with 429 or 5xx are retried in a later batch;
+// if a batch call fails as a whole, the remaining ids go through the
+// fallback one by one.
+type BatchFetcher struct {
+	client   *http.Client
+	endpoint string
+	fallback MessageFetcher
+}
+
+func (f *BatchFetcher) Metadata(ctx context.Context, ids, headers []string) (*FetchResult, error) {
+	out := newFetchResult()
+	pending := ids
+	for attempt := 0; len(pending) > 0; attempt++ {
+		if attempt > 0 {
+			if attempt >= maxPartAttempts {
+				break
+			}
+			if err := sleepBackoff(ctx, attempt); err != nil {
+				return out, err
+			}
+		}
+
+		var retry []string
+		throttled := false
+		for start := 0; start < len(pending); start += maxBatchParts {
+			end := min(start+maxBatchParts, len(pending))
+			chunk := pending[start:end]
+
+			got, err := f.do(ctx, chunk, headers)
+			if ctx.Err() != nil {
+				return out, ctx.Err()
+			}
+			if fatalFetchError(err) {
+				return out, err
+			}
+			if err != nil {
+				// The batch call itself failed; don't let one bad endpoint
+				// response cost the whole scan.
+				rest := append(retry, pending[start:]...)
+				for _, id := range rest {
+					delete(out.Errors, id)
+				}
+				got, ferr := f.fallback.Metadata(ctx, rest, headers)
+				mergeFetch(out, got)
+				return out, ferr
+			}
+			for _, id := range chunk {
+				r := got[id]
+				switch {
+				case r == nil:
+					retry = append(retry, id)
+				case r.msg != nil:
+					out.Messages[id] = r.msg
+					delete(out.Errors, id)
+				case retryableStatus(r.err):
+					out.Errors[id] = r.err
+					retry = append(retry, id)
+					throttled = true
+				default:
+					out.Errors[id] = r.err
+				}
+			}
+		}
+		if throttled {
+			reportThrottle(f.client)
+		}
+		pending = retry
+	}
+	for _, id := range pending {
+		if _, ok := out.Errors[id]; !ok {
+			out.Errors[id] = fmt.Errorf("no response for message %s in batch", id)
+		}
+	}
+	return out, nil
+}
+
+type batchPart struct {
+	msg *gmail.Message
+	err error
+}
+
+// do sends one batch and returns the parsed result of every part it got
+// back, keyed by message id.
+func (f *BatchFetcher) do(ctx context.Context, ids, headers []string) (map[string]*batchPart, error) {
+	q := url.Values{"format": {"metadata"}}
+	for _, h := range headers {
+		q.Add("metadataHeaders", h)
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for i, id := range ids {
+		h := textproto.MIMEHeader{}
+		h.Set("Content-Type", "application/http")
+		h.Set("Content-ID", "<item-"+strconv.Itoa(i)+">")
+		pw, err := mw.CreatePart(h)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(pw, "GET /gmail/v1/users/me/messages/%s?%s HTTP/1.1\r\nAccept: application/json\r\n\r\n",
+			url.PathEscape(id), q.Encode())
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	// Every sub-request costs what it would on its own.
+	ctx = WithQuotaUnits(ctx, 5*int64(len(ids)))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.endpoint, &body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "multipart/mixed; boundary="+mw.Boundary())
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := googleapi.CheckResponse(resp); err != nil {
+		return nil, err
+	}
+
+	mediaType, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") {
+		return nil, fmt.Errorf("unexpected batch response type %q", resp.Header.Get("Content-Type"))
+	}
+
+	out := make(map[string]*batchPart, len(ids))
+	mr := multipart.NewReader(resp.Body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return out, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read batch response: %w", err)
+		}
+		i, ok := batchItemIndex(part.Header.Get("Content-ID"))
+		if !ok || i >= len(ids) {
+			continue
+		}
+		out[ids[i]] = readBatchPart(part)
+	}
+}
+
+// batchItemIndex reads the index back out of "<response-item-3>".
+func batchItemIndex(contentID string) (int, bool) {
+	s := strings.Trim(contentID, "<>")
+	k := strings.LastIndex(s, "item-")
+	if k == -1 {
+		return 0, false
+	}
+	i, err := strconv.Atoi(s[k+len("item-"):])
+	return i, err == nil
+}
+
+func readBatchPart(r io.Reader) *batchPart {
+	resp, err := http.ReadResponse(bufio.NewReader(r), nil)
+	if err != nil {
+		return &batchPart{err: fmt.Errorf("malformed batch part: %w", err)}
+	}
+	defer resp.Body.Close()
+	if err := googleapi.CheckResponse(resp); err != nil {
+		return &batchPart{err: err}
+	}
+	msg := &gmail.Message{}
+	if err := json.NewDecoder(resp.Body).Decode(msg); err != nil {
+		return &batchPart{err: fmt.Errorf("failed to decode batch part: %w", err)}
+	}
+	return &batchPart{msg: msg}
+}
+
+func retryableStatus(err error) bool {
+	var gerr *googleapi.Error
+	if !errors.As(err, &gerr) {
+		return false
+	}
+	return gerr.Code == http.StatusTooManyRequests || gerr.Code >= 500 ||
+		(gerr.Code == http.StatusForbidden && strings.Contains(strings.ToLower(gerr.Message), "rate limit"))
+}
+
+// sleepBackoff waits 1s, 2s, 4s... with up to 50% jitter.
+func sleepBackoff(ctx context.Context, attempt int) error {
+	d := time.Second << (attempt - 1)
+	d += time.Duration(rand.Int63n(int64(d / 2)))
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+func mergeFetch(dst, src *FetchResult) {
+	if src == nil {
+		return
+	}
+	for id, m := range src.Messages {
+		dst.Messages[id] = m
+	}
+	for id, err := range src.Errors {
+		dst.Errors[id] = err
+	}
+}
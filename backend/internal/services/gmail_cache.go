@@ -0,0 +1,100 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gant123/jobTracker/internal/models"
+	"github.com/gant123/jobTracker/internal/repository"
+)
+
+// ---------- Message cache ----------
+
+// MessageCache remembers the headers of messages a user's scans fetched,
+// and what the scanner made of them, so repeat scans skip Gmail for
+// messages that were previewed but never imported. It only speeds scans
+// up: a failing cache falls back to fetching.
+type MessageCache struct {
+	repo   *repository.MessageCacheRepository
+	userID int
+}
+
+func NewMessageCache(repo *repository.MessageCacheRepository, userID int) *MessageCache {
+	return &MessageCache{repo: repo, userID: userID}
+}
+
+func (c *MessageCache) lookup(ids []string) map[string]*models.CachedMessage {
+	if c == nil {
+		return nil
+	}
+	entries, err := c.repo.GetMany(c.userID, ids)
+	if err != nil {
+		return nil
+	}
+	return entries
+}
+
+func (c *MessageCache) store(meta messageMeta, version, class string, evs []EmailJobEvent) {
+	if c == nil {
+		return
+	}
+	if evs == nil {
+		evs = []EmailJobEvent{}
+	}
+	data, err := json.Marshal(evs)
+	if err != nil {
+		return
+	}
+	_ = c.repo.Put(&models.CachedMessage{
+		UserID:            c.userID,
+		GmailMessageID:    meta.ID,
+		ThreadID:          meta.ThreadID,
+		Subject:           meta.Subject,
+		From:              meta.From,
+		To:                meta.To,
+		Snippet:           meta.Snippet,
+		Date:              meta.Date,
+		ClassifierVersion: version,
+		Class:             class,
+		Events:            data,
+	})
+}
+
+func metaFromCache(m *models.CachedMessage) messageMeta {
+	return messageMeta{
+		ID:       m.GmailMessageID,
+		ThreadID: m.ThreadID,
+		Subject:  m.Subject,
+		From:     m.From,
+		To:       m.To,
+		Snippet:  m.Snippet,
+		Date:     m.Date,
+	}
+}
+
+// cachedVerdict returns the cached events for a message if they were
+// produced by the current classifier version and for the same kind of
+// scan (sent vs. inbound).
+func cachedVerdict(m *models.CachedMessage, version string, sent bool) ([]EmailJobEvent, string, bool) {
+	if m == nil || m.ClassifierVersion != version || (m.Class == classSent) != sent {
+		return nil, "", false
+	}
+	var evs []EmailJobEvent
+	if err := json.Unmarshal(m.Events, &evs); err != nil {
+		return nil, "", false
+	}
+	return evs, m.Class, true
+}
+
+// version identifies everything that shapes a verdict: the extractors, the
+// feature set, the user's trained model and their phrase packs. Sender
+// rules and dismissals filter before classification and are left out.
+func (p *ScanProfile) version() string {
+	model := "0"
+	if nb := p.model(); nb.Ready() {
+		model = strconv.FormatInt(nb.TrainedAt.Unix(), 10)
+	}
+	return fmt.Sprintf("%d.%d.%s.%s", ExtractorVersion, ClassifierVersion, model, strings.Join(p.languages(), "+"))
+}
@@ -0,0 +1,283 @@
+package services
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	gmail "google.golang.org/api/gmail/v1"
+)
+
+// ---------- Job-alert digests ----------
+
+// Job boards send both application confirmations and alert digests from
+// the same domains. Confirmations are one application; digests list many
+// postings the user never applied to.
+var digestSenders = []string{
+	"linkedin.com", "indeed.com", "glassdoor.com", "ziprecruiter.com", "monster.com",
+}
+
+var (
+	reDigestSubject = regexp.MustCompile(`(?i)(job alert|jobs? for you|new jobs?|jobs? (?:matching|similar)|recommended jobs?|is hiring|are hiring|\d+\+? (?:new )?(?:jobs|opportunities|openings)|jobs you may be interested in|top job picks)`)
+	reConfirmation  = regexp.MustCompile(`(?i)(your application (?:was sent|to|for|has been)|application (?:submitted|received|confirmation)|thanks? (?:you )?for applying|indeed application:|you applied)`)
+	// Links that point at a single posting on a job board.
+	rePostingURL = regexp.MustCompile(`(?i)https?://[^\s"'<>]*(?:linkedin\.com/(?:comm/)?jobs/view/|indeed\.com/(?:rc/clk|viewjob|pagead/clk|m/viewjob)|glassdoor\.com/(?:job-listing|partner/jobListing)|ziprecruiter\.com/(?:jobs|c/|k/))[^\s"'<>]*`)
+	// "Acme · San Francisco, CA" / "Acme - Remote"
+	reCompanyLocation = regexp.MustCompile(`^(.+?)\s+(?:·|•|\||-|–)\s+(.+)$`)
+)
+
+// Lines in digests that are chrome, not posting data.
+var digestNoise = []string{
+	"view job", "apply now", "easy apply", "see all jobs", "unsubscribe", "new", "promoted",
+	"actively recruiting", "be an early applicant", "applicants", "alumni", "connection",
+	"connections", "save", "see more jobs", "manage alerts", "job alert",
+}
+
+// isDigestCandidate reports whether a message looks like a job-board alert
+// rather than a confirmation of an application.
+func isDigestCandidate(m messageMeta) bool {
+	from := strings.ToLower(m.From)
+	board := false
+	for _, d := range digestSenders {
+		if strings.Contains(from, d) {
+			board = true
+			break
+		}
+	}
+	if !board || reConfirmation.MatchString(m.Subject) {
+		return false
+	}
+	return reDigestSubject.MatchString(m.Subject) || reDigestSubject.MatchString(m.Snippet)
+}
+
+// fetchDigestEvents downloads a digest's body and turns every posting in it
+// into its own wishlist lead.
+func fetchDigestEvents(ctx context.Context, srv *gmail.Service, m messageMeta) ([]EmailJobEvent, error) {
+	msg, err := srv.Users.Messages.Get("me", m.ID).Format("full").Context(ctx).Do()
+	if err != nil {
+		return nil, err
+	}
+	var text, rawHTML string
+	var atts []ThreadAttachment
+	walkParts(msg.Payload, &text, &rawHTML, &atts)
+
+	var postings []digestPosting
+	if text != "" {
+		postings = parseDigestText(text)
+	}
+	if len(postings) == 0 && rawHTML != "" {
+		postings = parseDigestHTML(rawHTML)
+	}
+
+	out := make([]EmailJobEvent, 0, len(postings))
+	for _, p := range postings {
+		out = append(out, EmailJobEvent{
+			MessageID:   m.ID,
+			ThreadID:    m.ThreadID,
+			Subject:     m.Subject,
+			From:        m.From,
+			Snippet:     m.Snippet,
+			Company:     p.Company,
+			Title:       p.Title,
+			Location:    p.Location,
+			URL:         p.URL,
+			SourceKey:   p.URL,
+			Salary:      p.Salary,
+			Status:      "wishlist",
+			AppliedDate: m.Date,
+			Source:      "gmail",
+			Direction:   "inbound",
+			Link:        gmailLink(m.ID),
+		})
+	}
+	return out, nil
+}
+
+type digestPosting struct {
+	Title    string
+	Company  string
+	Location string
+	URL      string
+	Salary   *Salary
+}
+
+// parseDigestText reads plain-text digests, where each posting's details
+// come first and its link last:
+//
+//	Software Engineer
+//	Acme · San Francisco, CA
+//	View job: https://www.linkedin.com/comm/jobs/view/123/
+func parseDigestText(body string) []digestPosting {
+	var out []digestPosting
+	seen := map[string]bool{}
+	var buf []string
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if u := rePostingURL.FindString(line); u != "" {
+			key := canonicalPostingURL(u)
+			if !seen[key] {
+				if p, ok := postingFromLines(buf, key); ok {
+					seen[key] = true
+					out = append(out, p)
+				}
+			}
+			buf = buf[:0]
+			continue
+		}
+		if !isDigestNoise(line) {
+			buf = append(buf, line)
+		}
+	}
+	return out
+}
+
+// parseDigestHTML reads HTML digests, where a posting starts at its link
+// and runs until the next posting link. The first text inside a posting
+// link is taken as its title, wherever the board puts it.
+func parseDigestHTML(body string) []digestPosting {
+	var out []digestPosting
+	seen := map[string]bool{}
+	current := ""
+	var buf []string
+	inLink := false
+	titleAt := -1
+	flush := func() {
+		if titleAt > 0 {
+			title := buf[titleAt]
+			copy(buf[1:titleAt+1], buf[:titleAt])
+			buf[0] = title
+		}
+		titleAt = -1
+		if current != "" && !seen[current] {
+			if p, ok := postingFromLines(buf, current); ok {
+				seen[current] = true
+				out = append(out, p)
+			}
+		}
+		buf = buf[:0]
+	}
+
+	z := html.NewTokenizer(strings.NewReader(body))
+	skipDepth := 0
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			flush()
+			return out
+		case html.StartTagToken, html.EndTagToken, html.SelfClosingTagToken:
+			tok := z.Token()
+			if droppedTags[tok.Data] {
+				if tt == html.StartTagToken {
+					skipDepth++
+				} else if tt == html.EndTagToken && skipDepth > 0 {
+					skipDepth--
+				}
+				continue
+			}
+			if tok.Data == "a" && tt == html.EndTagToken {
+				inLink = false
+			}
+			if tt == html.StartTagToken && tok.Data == "a" {
+				for _, a := range tok.Attr {
+					if a.Key != "href" || !rePostingURL.MatchString(a.Val) {
+						continue
+					}
+					if key := canonicalPostingURL(a.Val); key != current {
+						flush()
+						current = key
+					}
+					inLink = true
+				}
+			}
+		case html.TextToken:
+			if skipDepth > 0 || current == "" {
+				continue
+			}
+			line := strings.Join(strings.Fields(html.UnescapeString(string(z.Text()))), " ")
+			if line != "" && !isDigestNoise(line) {
+				if inLink && titleAt == -1 {
+					titleAt = len(buf)
+				}
+				buf = append(buf, line)
+			}
+		}
+	}
+}
+
+// postingFromLines maps the detail lines of one posting onto
+// title/company/location. A "Company · Location" line anchors the match,
+// with the title on the line before it.
+func postingFromLines(lines []string, link string) (digestPosting, bool) {
+	p := digestPosting{URL: link}
+	// Boards list pay on its own line ("$120K/yr - $150K/yr").
+	if sal, rest, ok := salaryFromLines(lines); ok {
+		p.Salary, lines = &sal, rest
+	}
+	for k := len(lines) - 1; k >= 1; k-- {
+		if m := reCompanyLocation.FindStringSubmatch(lines[k]); len(m) > 2 {
+			p.Title = lines[k-1]
+			p.Company, p.Location = strings.TrimSpace(m[1]), strings.TrimSpace(m[2])
+			return p, len(p.Title) <= 200
+		}
+	}
+
+	// No anchor: plain-text digests can carry the previous posting's
+	// trailing lines, so only the last few before the link belong to it.
+	if len(lines) > 3 {
+		lines = lines[len(lines)-3:]
+	}
+	switch len(lines) {
+	case 0:
+		return digestPosting{}, false
+	case 1:
+		p.Title = lines[0]
+	case 2:
+		p.Title, p.Company = lines[0], lines[1]
+	default:
+		p.Title, p.Company, p.Location = lines[0], lines[1], lines[2]
+	}
+	return p, len(p.Title) <= 200
+}
+
+func isDigestNoise(line string) bool {
+	low := strings.ToLower(strings.Trim(line, " :·•|-"))
+	if low == "" || len(low) < 2 {
+		return true
+	}
+	for _, n := range digestNoise {
+		if low == n || strings.HasPrefix(low, n+":") {
+			return true
+		}
+	}
+	return false
+}
+
+// Query parameters that identify the posting on links whose path doesn't:
+// Indeed's jk, Glassdoor's jl and jobListingId, ZipRecruiter's jid.
+var postingIDParams = []string{"jk", "jl", "jobListingId", "jid"}
+
+// canonicalPostingURL drops tracking parameters so the same posting linked
+// several times in one digest collapses to one lead, keeping the first
+// parameter that identifies the posting.
+func canonicalPostingURL(raw string) string {
+	u, err := url.Parse(strings.TrimRight(raw, ".,)>"))
+	if err != nil {
+		return raw
+	}
+	q := url.Values{}
+	for _, name := range postingIDParams {
+		if v := u.Query().Get(name); v != "" {
+			q.Set(name, v)
+			break
+		}
+	}
+	u.RawQuery = q.Encode()
+	u.Fragment = ""
+	return u.String()
+}
@@ -0,0 +1,234 @@
+package services
+
+import "testing"
+
+func TestIsDigestCandidate(t *testing.T) {
+	tests := []struct {
+		subject, snippet, from string
+		want                   bool
+	}{
+		{"“golang developer”: 12 new jobs", "", "LinkedIn Job Alerts <jobalerts-noreply@linkedin.com>", true},
+		{"Acme is hiring a Backend Engineer", "", "LinkedIn <jobs-listings@linkedin.com>", true},
+		{"Software Engineer jobs for you", "", "Indeed <alert@indeed.com>", true},
+		{"Glassdoor", "Recommended jobs for Backend Engineer in Austin", "Glassdoor Jobs <noreply@glassdoor.com>", true},
+		{"Your application was sent to Acme", "", "LinkedIn <jobs-noreply@linkedin.com>", false},
+		{"Indeed Application: Backend Engineer", "5 new jobs", "Indeed Apply <indeedapply@indeed.com>", false},
+		{"12 new jobs for you", "", "Acme Careers <careers@acme.com>", false},
+		{"Your weekly network update", "", "LinkedIn <messages-noreply@linkedin.com>", false},
+	}
+	for _, tt := range tests {
+		if got := isDigestCandidate(messageMeta{Subject: tt.subject, Snippet: tt.snippet, From: tt.from}); got != tt.want {
+			t.Errorf("isDigestCandidate(%q from %q) = %v, want %v", tt.subject, tt.from, got, tt.want)
+		}
+	}
+}
+
+func TestCanonicalPostingURL(t *testing.T) {
+	tests := []struct {
+		a, b string
+		same bool
+	}{
+		{
+			"https://www.linkedin.com/comm/jobs/view/3812345678/?trackingId=abc%3D%3D&refId=1&lipi=x",
+			"https://www.linkedin.com/comm/jobs/view/3812345678/?trackingId=def&midToken=y#details",
+			true,
+		},
+		{
+			"https://www.linkedin.com/comm/jobs/view/3812345678/",
+			"https://www.linkedin.com/comm/jobs/view/3899999999/",
+			false,
+		},
+		{
+			"https://www.indeed.com/rc/clk?jk=7f3a9c1e2b4d5f60&from=ja&tk=1hq",
+			"https://www.indeed.com/rc/clk?tk=2ab&jk=7f3a9c1e2b4d5f60&alid=9",
+			true,
+		},
+		{
+			"https://www.indeed.com/rc/clk?jk=7f3a9c1e2b4d5f60&from=ja",
+			"https://www.indeed.com/rc/clk?jk=0000aaaa1111bbbb&from=ja",
+			false,
+		},
+		{
+			"https://www.glassdoor.com/partner/jobListing.htm?pos=101&ao=1136043&jobListingId=1009012345678&utm_source=jobsAlert",
+			"https://www.glassdoor.com/partner/jobListing.htm?pos=102&ao=85058&jobListingId=1009012345678&utm_campaign=x",
+			true,
+		},
+		{
+			"https://www.glassdoor.com/partner/jobListing.htm?pos=101&jobListingId=1009012345678",
+			"https://www.glassdoor.com/partner/jobListing.htm?pos=102&jobListingId=1009087654321",
+			false,
+		},
+		{
+			"https://www.glassdoor.com/job-listing/backend-engineer-acme-JV_IC1139761_KO0,16_KE17,21.htm?jl=1009012345678&utm_medium=email",
+			"https://www.glassdoor.com/job-listing/backend-engineer-acme-JV_IC1139761_KO0,16_KE17,21.htm?jl=1009012345678",
+			true,
+		},
+		{
+			"https://www.linkedin.com/comm/jobs/view/3812345678/).",
+			"https://www.linkedin.com/comm/jobs/view/3812345678/",
+			true,
+		},
+	}
+	for _, tt := range tests {
+		ka, kb := canonicalPostingURL(tt.a), canonicalPostingURL(tt.b)
+		if (ka == kb) != tt.same {
+			t.Errorf("canonicalPostingURL:\n %s -> %s\n %s -> %s\nsame = %v, want %v", tt.a, ka, tt.b, kb, ka == kb, tt.same)
+		}
+	}
+}
+
+const linkedInDigestText = `Your job alert for golang developer in United States
+12 new jobs match your preferences.
+
+Senior Backend Engineer
+Acme · San Francisco, CA (Hybrid)
+$150K/yr - $190K/yr
+Actively recruiting
+View job: https://www.linkedin.com/comm/jobs/view/3812345678/?trackingId=abc%3D%3D&refId=1
+
+Go Developer
+Globex Corporation · United States (Remote)
+Be an early applicant
+View job: https://www.linkedin.com/comm/jobs/view/3823456789/?trackingId=def&refId=2
+
+Senior Backend Engineer
+Acme · San Francisco, CA (Hybrid)
+View job: https://www.linkedin.com/comm/jobs/view/3812345678/?trackingId=zzz&refId=9
+
+See all jobs: https://www.linkedin.com/comm/jobs/search?keywords=golang
+Unsubscribe: https://www.linkedin.com/comm/psettings/email-unsubscribe
+`
+
+const indeedDigestText = `Software Engineer jobs in Austin, TX
+
+Platform Engineer
+Initech
+Austin, TX
+https://www.indeed.com/rc/clk?jk=7f3a9c1e2b4d5f60&from=ja&tk=1hq
+
+Backend Developer
+Umbrella Labs
+Remote
+Easy apply
+https://www.indeed.com/rc/clk?jk=0000aaaa1111bbbb&from=ja&tk=1hq
+
+Manage alerts: https://www.indeed.com/alerts
+`
+
+const glassdoorDigestHTML = `<html><head><style>td{font-family:Arial}</style><title>Jobs</title></head><body>
+<table>
+<tr><td>
+  <a href="https://www.glassdoor.com/partner/jobListing.htm?pos=101&amp;ao=1136043&amp;jobListingId=1009012345678&amp;utm_source=jobsAlert"><img src="https://media.glassdoor.com/sql/1/acme.png" alt=""></a>
+</td><td>
+  <div>Hooli</div>
+  <a href="https://www.glassdoor.com/partner/jobListing.htm?pos=101&amp;ao=85058&amp;jobListingId=1009012345678&amp;utm_campaign=title"><b>Staff Software Engineer</b></a>
+  <div>Mountain View, CA</div>
+  <div>$180K - $240K (Employer est.)</div>
+  <a href="https://www.glassdoor.com/partner/jobListing.htm?pos=101&amp;jobListingId=1009012345678&amp;utm_content=apply">Easy Apply</a>
+</td></tr>
+<tr><td>
+  <a href="https://www.glassdoor.com/partner/jobListing.htm?pos=102&amp;ao=85058&amp;jobListingId=1009087654321"><b>Site Reliability Engineer</b></a>
+  <div>Pied Piper &middot; Palo Alto, CA</div>
+</td></tr>
+</table>
+<p><a href="https://www.glassdoor.com/Job/jobs.htm?sc.keyword=engineer">See more jobs</a></p>
+<script>track()</script>
+</body></html>`
+
+func TestParseDigestText(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []digestPosting
+	}{
+		{
+			name: "linkedin",
+			body: linkedInDigestText,
+			want: []digestPosting{
+				{Title: "Senior Backend Engineer", Company: "Acme", Location: "San Francisco, CA (Hybrid)",
+					URL: "https://www.linkedin.com/comm/jobs/view/3812345678/"},
+				{Title: "Go Developer", Company: "Globex Corporation", Location: "United States (Remote)",
+					URL: "https://www.linkedin.com/comm/jobs/view/3823456789/"},
+			},
+		},
+		{
+			name: "indeed",
+			body: indeedDigestText,
+			want: []digestPosting{
+				{Title: "Platform Engineer", Company: "Initech", Location: "Austin, TX",
+					URL: "https://www.indeed.com/rc/clk?jk=7f3a9c1e2b4d5f60"},
+				{Title: "Backend Developer", Company: "Umbrella Labs", Location: "Remote",
+					URL: "https://www.indeed.com/rc/clk?jk=0000aaaa1111bbbb"},
+			},
+		},
+		{
+			name: "no postings",
+			body: "Hi Sam,\nHere is your weekly summary.\nhttps://www.linkedin.com/feed/\n",
+		},
+	}
+	for _, tt := range tests {
+		checkPostings(t, tt.name, parseDigestText(tt.body), tt.want)
+	}
+	if got := parseDigestText(linkedInDigestText); len(got) > 0 && got[0].Salary == nil {
+		t.Error("linkedin: salary line not parsed")
+	}
+}
+
+func TestParseDigestHTML(t *testing.T) {
+	got := parseDigestHTML(glassdoorDigestHTML)
+	checkPostings(t, "glassdoor", got, []digestPosting{
+		{Title: "Staff Software Engineer", Company: "Hooli", Location: "Mountain View, CA",
+			URL: "https://www.glassdoor.com/partner/jobListing.htm?jobListingId=1009012345678"},
+		{Title: "Site Reliability Engineer", Company: "Pied Piper", Location: "Palo Alto, CA",
+			URL: "https://www.glassdoor.com/partner/jobListing.htm?jobListingId=1009087654321"},
+	})
+	if len(got) > 0 && got[0].Salary == nil {
+		t.Error("glassdoor: salary line not parsed")
+	}
+}
+
+func TestPostingFromLines(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		ok    bool
+		want  digestPosting
+	}{
+		{"anchor line", []string{"Backend Engineer", "Acme · Remote"}, true,
+			digestPosting{Title: "Backend Engineer", Company: "Acme", Location: "Remote"}},
+		{"anchor after leftovers", []string{"Previous posting tail", "Data Engineer", "Globex | Berlin, Germany"}, true,
+			digestPosting{Title: "Data Engineer", Company: "Globex", Location: "Berlin, Germany"}},
+		{"three lines", []string{"Platform Engineer", "Initech", "Austin, TX"}, true,
+			digestPosting{Title: "Platform Engineer", Company: "Initech", Location: "Austin, TX"}},
+		{"only the last three lines", []string{"Old Title", "Old Co", "QA Engineer", "Hooli", "Remote"}, true,
+			digestPosting{Title: "QA Engineer", Company: "Hooli", Location: "Remote"}},
+		{"title only", []string{"Go Developer"}, true, digestPosting{Title: "Go Developer"}},
+		{"nothing", nil, false, digestPosting{}},
+	}
+	for _, tt := range tests {
+		got, ok := postingFromLines(tt.lines, "")
+		if ok != tt.ok {
+			t.Errorf("%s: ok = %v, want %v", tt.name, ok, tt.ok)
+			continue
+		}
+		if got.Title != tt.want.Title || got.Company != tt.want.Company || got.Location != tt.want.Location {
+			t.Errorf("%s: got %q / %q / %q, want %q / %q / %q", tt.name,
+				got.Title, got.Company, got.Location, tt.want.Title, tt.want.Company, tt.want.Location)
+		}
+	}
+}
+
+func checkPostings(t *testing.T, name string, got, want []digestPosting) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Errorf("%s: got %d postings, want %d: %+v", name, len(got), len(want), got)
+		return
+	}
+	for i := range want {
+		g, w := got[i], want[i]
+		if g.Title != w.Title || g.Company != w.Company || g.Location != w.Location || g.URL != w.URL {
+			t.Errorf("%s[%d]: got %q / %q / %q / %s\nwant %q / %q / %q / %s", name, i,
+				g.Title, g.Company, g.Location, g.URL, w.Title, w.Company, w.Location, w.URL)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package services
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+
+	"github.com/gant123/jobTracker/internal/models"
+	"github.com/gant123/jobTracker/internal/repository"
+)
+
+// ---------- Sender allow/block lists ----------
+
+// Gmail rejects very long queries; past this many rules we rely on
+// post-filtering alone.
+const maxQueryRules = 40
+
+// SenderRules is a user's allow and block lists. Patterns are either a
+// full address (jobs@acme.com) or a domain (acme.com), which also covers
+// its subdomains.
+type SenderRules struct {
+	Allow []string
+	Block []string
+}
+
+func NewSenderRules(rules []*models.SenderRule) *SenderRules {
+	sr := &SenderRules{}
+	for _, r := range rules {
+		switch r.Kind {
+		case "allow":
+			sr.Allow = append(sr.Allow, r.Pattern)
+		case "block":
+			sr.Block = append(sr.Block, r.Pattern)
+		}
+	}
+	return sr
+}
+
+// Blocked reports whether mail from this sender should be skipped. An
+// allow rule wins over a block rule, so "block linkedin.com" plus "allow
+// jobs-noreply@linkedin.com" keeps the latter.
+func (sr *SenderRules) Blocked(from string) bool {
+	if sr == nil {
+		return false
+	}
+	addr := senderAddress(from)
+	for _, p := range sr.Allow {
+		if senderMatches(addr, p) {
+			return false
+		}
+	}
+	for _, p := range sr.Block {
+		if senderMatches(addr, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyToQuery widens q to allowlisted senders and excludes blocked ones.
+func (sr *SenderRules) applyToQuery(q string) string {
+	if sr == nil {
+		return q
+	}
+	if n := len(sr.Allow); n > 0 && n <= maxQueryRules {
+		froms := make([]string, 0, n)
+		for _, p := range sr.Allow {
+			froms = append(froms, "from:"+p)
+		}
+		q = q + " OR " + joined(froms)
+	}
+	if len(sr.Allow)+len(sr.Block) <= maxQueryRules {
+		for _, p := range sr.Block {
+			q += " -from:" + p
+		}
+	}
+	return q
+}
+
+func senderAddress(from string) string {
+	if a, err := mail.ParseAddress(from); err == nil {
+		return strings.ToLower(a.Address)
+	}
+	return strings.ToLower(strings.Trim(from, " <>"))
+}
+
+func senderMatches(addr, pattern string) bool {
+	pattern = strings.ToLower(pattern)
+	if strings.Contains(pattern, "@") {
+		return addr == pattern
+	}
+	i := strings.LastIndex(addr, "@")
+	if i == -1 {
+		return false
+	}
+	domain := addr[i+1:]
+	return domain == pattern || strings.HasSuffix(domain, "."+pattern)
+}
+
+// ValidSenderPattern checks a rule pattern is an address or a domain.
+func ValidSenderPattern(p string) error {
+	p = strings.TrimSpace(p)
+	if p == "" || strings.ContainsAny(p, " \t()\"{}") {
+		return fmt.Errorf("pattern must be an email address or a domain")
+	}
+	if i := strings.Index(p, "@"); i != -1 {
+		if i == 0 || strings.Count(p, "@") > 1 || !strings.Contains(p[i+1:], ".") {
+			return fmt.Errorf("invalid email address")
+		}
+		return nil
+	}
+	if !strings.Contains(p, ".") {
+		return fmt.Errorf("invalid domain")
+	}
+	return nil
+}
+
+// ---------- Profiles ----------
+
+// ScanProfiles assembles the per-user state a scan needs.
+type ScanProfiles struct {
+	classifier *ClassifierService
+	filters    *repository.ScanFilterRepository
+	cache      *repository.MessageCacheRepository
+}
+
+func NewScanProfiles(classifier *ClassifierService, filters *repository.ScanFilterRepository, cache *repository.MessageCacheRepository) *ScanProfiles {
+	return &ScanProfiles{classifier: classifier, filters: filters, cache: cache}
+}
+
+func (p *ScanProfiles) Load(userID int) (*ScanProfile, error) {
+	model, err := p.classifier.Model(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load classifier model: %w", err)
+	}
+	rules, err := p.filters.ListRules(userID)
+	if err != nil {
+		return nil, err
+	}
+	dismissed, err := p.filters.DismissedMessageIDs(userID)
+	if err != nil {
+		return nil, err
+	}
+	langs, err := p.filters.Languages(userID)
+	if err != nil {
+		return nil, err
+	}
+	return &ScanProfile{
+		Model:     model,
+		Senders:   NewSenderRules(rules),
+		Dismissed: dismissed,
+		Languages: NewLanguages(langs),
+		Cache:     NewMessageCache(p.cache, userID),
+	}, nil
+}
@@ -0,0 +1,337 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gant123/jobTracker/internal/jobs"
+	"github.com/gant123/jobTracker/internal/models"
+	"github.com/gant123/jobTracker/internal/repository"
+	"github.com/sirupsen/logrus"
+	gmail "google.golang.org/api/gmail/v1"
+	"google.golang.org/api/option"
+)
+
+// ---------- Gmail background jobs ----------
+
+// GmailSyncJob imports the last year of a user's mailbox. A full sync is
+// worth waiting out a Gmail outage for. Users may schedule it to catch up
+// on recent mail.
+var GmailSyncJob = jobs.Type[GmailSyncPayload]{
+	Name:        "gmail_initial_sync",
+	Retry:       &repository.RetryPolicy{MaxAttempts: 5, BaseDelay: time.Minute, MaxDelay: time.Hour},
+	Schedulable: true,
+}
+
+type GmailSyncPayload struct {
+	IncludeSent bool `json:"include_sent"`
+	// Days limits the sync to recent mail; 0 means the last year.
+	Days int `json:"days,omitempty"`
+}
+
+// GmailRescanJob is the nightly system job that queues a catch-up sync
+// for every connected mailbox, for mail the user never synced by hand.
+var GmailRescanJob = jobs.Type[jobs.None]{Name: "gmail_rescan", Priority: repository.PriorityMaintenance}
+
+// rescanDays overlaps the nightly runs so a missed night isn't a gap.
+const rescanDays = 3
+
+// GmailJobs runs the jobs that need a user's Gmail client.
+type GmailJobs struct {
+	oauth        *GoogleOAuth
+	tokenRepo    repository.TokenRepository
+	jobRepo      *repository.JobRepository
+	jobEmailRepo *repository.JobEmailRepository
+	contactRepo  *repository.JobContactRepository
+	syncRepo     *repository.GmailSyncRepository
+	profiles     *ScanProfiles
+	reclassifier *ReclassifyService
+	postings     *PostingService
+	jobQueue     *repository.JobQueueRepository
+}
+
+func NewGmailJobs(
+	oauth *GoogleOAuth,
+	tokenRepo repository.TokenRepository,
+	jobRepo *repository.JobRepository,
+	jobEmailRepo *repository.JobEmailRepository,
+	contactRepo *repository.JobContactRepository,
+	syncRepo *repository.GmailSyncRepository,
+	profiles *ScanProfiles,
+	reclassifier *ReclassifyService,
+	postings *PostingService,
+	jobQueue *repository.JobQueueRepository,
+) *GmailJobs {
+	return &GmailJobs{
+		oauth:        oauth,
+		tokenRepo:    tokenRepo,
+		jobRepo:      jobRepo,
+		jobEmailRepo: jobEmailRepo,
+		contactRepo:  contactRepo,
+		syncRepo:     syncRepo,
+		profiles:     profiles,
+		reclassifier: reclassifier,
+		postings:     postings,
+		jobQueue:     jobQueue,
+	}
+}
+
+// Register installs the Gmail job handlers.
+func (g *GmailJobs) Register(r *jobs.Registry) {
+	jobs.Register(r, GmailSyncJob, g.InitialSync)
+	jobs.Register(r, ReclassifyJob, g.Reclassify)
+	jobs.Register(r, GmailRescanJob, g.Rescan)
+}
+
+// gmailOutcome tells the worker how to treat a Gmail failure.
+func gmailOutcome(err error) error {
+	var qerr *QuotaExhaustedError
+	switch {
+	case errors.As(err, &qerr):
+		// Nothing is wrong with the job; pick it up again when the budget
+		// resets. Work already imported is skipped on the next run.
+		return jobs.RetryAt(qerr.RetryAt, err)
+	case errors.Is(err, ErrGrantRevoked):
+		// Retrying can't help until the user reconnects; park this job and
+		// the rest of their Gmail work instead of burning attempts.
+		return jobs.Pause(err, "gmail_")
+	case errors.Is(err, errGmailNotConnected):
+		// Queued before the user disconnected; there is nothing to resume.
+		return jobs.Permanent(err)
+	}
+	return err
+}
+
+// errGmailNotConnected means the user has no Gmail token at all.
+var errGmailNotConnected = errors.New("gmail not connected")
+
+func (g *GmailJobs) service(ctx context.Context, userID int) (*gmail.Service, MessageFetcher, error) {
+	tok, err := g.tokenRepo.Get(ctx, userID, "gmail")
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, errGmailNotConnected
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get token: %w", err)
+	}
+	client := g.oauth.UserClient(ctx, g.tokenRepo, userID, "gmail", tok)
+	srv, err := gmail.NewService(ctx, option.WithHTTPClient(client))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create gmail service: %w", err)
+	}
+	return srv, NewMessageFetcher(client, srv), nil
+}
+
+// stillConnected fails once the job was cancelled or the user's Gmail
+// token is gone.
+func (g *GmailJobs) stillConnected(ctx context.Context, userID int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if _, err := g.tokenRepo.Get(ctx, userID, "gmail"); errors.Is(err, sql.ErrNoRows) {
+		return errGmailNotConnected
+	} else if err != nil {
+		return fmt.Errorf("failed to get token: %w", err)
+	}
+	return nil
+}
+
+// InitialSync is the GmailSyncJob handler.
+func (g *GmailJobs) InitialSync(ctx context.Context, log *logrus.Entry, job *jobs.Job, p GmailSyncPayload) error {
+	return gmailOutcome(g.initialSync(ctx, log, job.UserID, p))
+}
+
+func (g *GmailJobs) initialSync(ctx context.Context, log *logrus.Entry, userID int, p GmailSyncPayload) error {
+	log.WithField("days", p.Days).Info("Starting Gmail sync")
+
+	srv, fetcher, err := g.service(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	// Mark sync started; catch-up syncs run quietly
+	if p.Days == 0 {
+		g.syncRepo.UpdateSyncStarted(userID)
+	}
+
+	// Get existing job IDs to avoid duplicates
+	existingIDs, err := g.jobRepo.GetAllGmailMessageIDsByUserID(userID)
+	if err != nil {
+		return fmt.Errorf("failed to get existing IDs: %w", err)
+	}
+
+	// Learned model, sender rules and dismissed messages for this user
+	profile, err := g.profiles.Load(userID)
+	if err != nil {
+		return fmt.Errorf("failed to load scan profile: %w", err)
+	}
+
+	// Scan last year of emails, fetching metadata in batches
+	scanner := NewGmailScanner()
+	since := time.Now().AddDate(-1, 0, 0)
+	if p.Days > 0 && p.Days < 365 {
+		since = time.Now().AddDate(0, 0, -p.Days)
+	}
+	until := time.Now()
+
+	totalImported := 0
+	modes := []string{"all"}
+	if p.IncludeSent {
+		modes = append(modes, "sent")
+	}
+
+	for _, mode := range modes {
+		pageToken := ""
+		for {
+			result, err := scanner.ScanPage(ctx, srv, fetcher, since, until, 100, pageToken, mode, existingIDs, profile)
+			if err != nil {
+				return fmt.Errorf("scan failed: %w", err)
+			}
+
+			// Import each job, unless the user disconnected meanwhile; their
+			// Gmail data must not come back after it was purged.
+			for _, event := range result.Events {
+				if err := g.stillConnected(ctx, userID); err != nil {
+					return err
+				}
+				if g.importEvent(log, userID, event) {
+					totalImported++
+				}
+			}
+			jobs.Progress(ctx)
+
+			// No pause between pages: the quota transport paces requests
+			// against the user's and the project's budgets.
+			if result.NextPageToken == "" {
+				break
+			}
+			pageToken = result.NextPageToken
+		}
+	}
+
+	// Mark sync completed
+	if p.Days == 0 {
+		g.syncRepo.UpdateSyncCompleted(userID, totalImported)
+	} else {
+		g.syncRepo.AddImported(userID, totalImported)
+	}
+
+	log.WithField("total_imported", totalImported).Info("Gmail sync completed")
+	return nil
+}
+
+// Rescan is the GmailRescanJob handler. Users with a sync already queued
+// or running are left to it.
+func (g *GmailJobs) Rescan(ctx context.Context, log *logrus.Entry, _ *jobs.Job, _ jobs.None) error {
+	userIDs, err := g.syncRepo.SyncedUserIDs()
+	if err != nil {
+		return fmt.Errorf("failed to list synced users: %w", err)
+	}
+	queued := 0
+	for _, uid := range userIDs {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		ok, err := GmailSyncJob.WithPriority(repository.PriorityMaintenance).
+			EnqueueUnique(g.jobQueue, uid, "", GmailSyncPayload{Days: rescanDays})
+		if err != nil {
+			return fmt.Errorf("failed to queue sync for user %d: %w", uid, err)
+		}
+		if ok {
+			queued++
+		}
+	}
+	log.WithFields(logrus.Fields{"users": len(userIDs), "queued": queued}).Info("Queued nightly Gmail syncs")
+	return nil
+}
+
+// Reclassify is the ReclassifyJob handler.
+func (g *GmailJobs) Reclassify(ctx context.Context, log *logrus.Entry, job *jobs.Job, _ jobs.None) error {
+	_, fetcher, err := g.service(ctx, job.UserID)
+	if err != nil {
+		return gmailOutcome(err)
+	}
+	res, err := g.reclassifier.Run(ctx, fetcher, job.UserID)
+	if err != nil {
+		return gmailOutcome(fmt.Errorf("reclassification failed: %w", err))
+	}
+	log.WithFields(logrus.Fields{
+		"checked":  res.Checked,
+		"proposed": res.Proposed,
+		"missing":  res.Missing,
+		"failed":   res.Failed,
+	}).Info("Reclassification finished")
+	return nil
+}
+
+// importEvent creates a job for a scanned email and links the email to it.
+// It reports whether a new job was created.
+func (g *GmailJobs) importEvent(log *logrus.Entry, userID int, event EmailJobEvent) bool {
+	job := &models.Job{
+		UserID:         userID,
+		Company:        event.Company,
+		Position:       event.Title,
+		Location:       event.Location,
+		Status:         event.Status,
+		URL:            event.URL,
+		GmailMessageID: event.MessageID,
+		SourceKey:      event.SourceKey,
+	}
+	if sal := event.Salary; sal != nil {
+		job.SalaryMin, job.SalaryMax = &sal.Min, &sal.Max
+		job.Currency, job.SalaryPeriod = sal.Currency, sal.Period
+	}
+	// A posting from an alert digest or a recruiter's outreach hasn't been
+	// applied to yet.
+	if event.Status != "wishlist" && event.Status != "lead" {
+		job.AppliedDate = &event.AppliedDate
+	}
+	if job.Company == "" {
+		job.Company = "Unknown Company"
+	}
+	if job.Position == "" {
+		job.Position = "Unknown Position"
+	}
+
+	if err := g.jobRepo.Create(job); err != nil {
+		return false
+	}
+
+	if err := g.jobEmailRepo.Create(&models.JobEmail{
+		JobID:          job.ID,
+		UserID:         userID,
+		GmailMessageID: event.MessageID,
+		ThreadID:       event.ThreadID,
+		Subject:        event.Subject,
+		From:           event.From,
+		Snippet:        event.Snippet,
+		ReceivedAt:     &event.AppliedDate,
+		Direction:      event.Direction,
+		Classification: event.Status,
+		Link:           event.Link,
+	}); err != nil && !errors.Is(err, repository.ErrDuplicate) {
+		log.WithError(err).Warn("Failed to link source email")
+	}
+
+	if c := event.Contact; c != nil && c.Email != "" {
+		if err := g.contactRepo.Create(&models.JobContact{
+			JobID:  job.ID,
+			UserID: userID,
+			Name:   c.Name,
+			Email:  c.Email,
+			Role:   c.Role,
+			Source: "gmail",
+		}); err != nil && !errors.Is(err, repository.ErrDuplicate) {
+			log.WithError(err).Warn("Failed to save contact")
+		}
+	}
+
+	// Digest leads link their posting, which may state the pay the alert
+	// left out.
+	if err := g.postings.Queue(job); err != nil {
+		log.WithError(err).Warn("Failed to queue posting salary lookup")
+	}
+	return true
+}
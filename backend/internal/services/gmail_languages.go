@@ -0,0 +1,340 @@
+package services
+
+import (
+	"regexp"
+	"strings"
+)
+
+// ---------- Phrase packs ----------
+
+// PhrasePack holds the phrases that identify application confirmations and
+// rejections in one language.
+type PhrasePack struct {
+	Lang string `json:"lang"`
+	Name string `json:"name"`
+
+	applicationQueries  []string
+	rejectionQueries    []string
+	rejectionIndicators []string
+	companyRes          []*regexp.Regexp
+	titleRes            []*regexp.Regexp
+	// Short function words used to tell the language of a message.
+	stopwords map[string]bool
+}
+
+// DefaultLanguage is the pack every scan includes and the fallback when a
+// message's language has no enabled pack.
+const DefaultLanguage = "en"
+
+var phrasePacks = map[string]*PhrasePack{
+	"en": {
+		Lang:                "en",
+		Name:                "English",
+		applicationQueries:  applicationQueries,
+		rejectionQueries:    rejectionQueries,
+		rejectionIndicators: rejectionIndicators,
+		companyRes:          companyRes,
+		titleRes:            titleRes,
+		stopwords: wordSet("the", "and", "your", "you", "for", "we", "our", "to", "of", "with",
+			"have", "has", "this", "that", "thank", "thanks", "application", "position", "will", "are"),
+	},
+	"de": {
+		Lang: "de",
+		Name: "Deutsch",
+		applicationQueries: []string{
+			`subject:"ihre bewerbung"`,
+			`subject:"deine bewerbung"`,
+			`subject:"eingangsbestätigung"`,
+			`subject:"bewerbungseingang"`,
+			`subject:"vielen dank für ihre bewerbung"`,
+			`subject:"danke für deine bewerbung"`,
+		},
+		rejectionQueries: []string{
+			`subject:absage`,
+			`subject:leider`,
+			`subject:"rückmeldung zu ihrer bewerbung"`,
+		},
+		rejectionIndicators: []string{
+			"leider", "absage", "nicht weiter berücksichtigen", "nicht berücksichtigen",
+			"anderen kandidaten", "anderen bewerber", "nicht in die engere auswahl",
+			"müssen wir ihnen mitteilen", "gegen ihre bewerbung entschieden",
+		},
+		companyRes: []*regexp.Regexp{
+			regexp.MustCompile(`(?i)(?:ihre|deine) bewerbung bei (?:der |dem )?([\p{L}\p{N}_\s\.\-&']+)`),
+			regexp.MustCompile(`(?i)bewerbung (?:an|für) (?:die |den |das )?([\p{L}\p{N}_\s\.\-&']+?) (?:als|eingegangen)\b`),
+		},
+		titleRes: []*regexp.Regexp{
+			regexp.MustCompile(`(?i)bewerbung als ([\p{L}\p{N}_\s\-/&'()]+?)(?:\s+(?:bei|\(m/w/d\))|\s*[.,;:!]|$)`),
+			regexp.MustCompile(`(?i)stelle (?:als|zum|zur) ([\p{L}\p{N}_\s\-/&']+?)(?:\s+bei|\s*[.,;:!]|$)`),
+		},
+		stopwords: wordSet("der", "die", "das", "und", "ihre", "ihr", "sie", "wir", "ihnen",
+			"für", "mit", "bei", "nicht", "eine", "einer", "vielen", "dank", "bewerbung", "haben", "uns"),
+	},
+	"fr": {
+		Lang: "fr",
+		Name: "Français",
+		applicationQueries: []string{
+			`subject:"votre candidature"`,
+			`subject:"ta candidature"`,
+			`subject:"candidature reçue"`,
+			`subject:"merci pour votre candidature"`,
+			`subject:"accusé de réception"`,
+		},
+		rejectionQueries: []string{
+			`subject:"suite à votre candidature"`,
+			`subject:"réponse à votre candidature"`,
+			`subject:malheureusement`,
+		},
+		rejectionIndicators: []string{
+			"malheureusement", "nous regrettons", "avons le regret", "ne pas donner suite",
+			"pas retenue", "pas été retenue", "pas retenu", "autres candidats", "un autre candidat",
+		},
+		companyRes: []*regexp.Regexp{
+			regexp.MustCompile(`(?i)(?:votre|ta) candidature (?:chez|à|a|auprès de) ([\p{L}\p{N}_\s\.\-&']+)`),
+			regexp.MustCompile(`(?i)rejoindre ([\p{L}\p{N}_\s\.\-&']+)`),
+		},
+		titleRes: []*regexp.Regexp{
+			regexp.MustCompile(`(?i)(?:au |pour le )?poste (?:de|d') ?([\p{L}\p{N}_\s\-/&']+?)(?:\s+(?:chez|à)\b|\s*[.,;:!]|$)`),
+		},
+		stopwords: wordSet("le", "la", "les", "et", "votre", "vous", "nous", "pour", "des", "une",
+			"est", "avec", "chez", "merci", "candidature", "sur", "pas", "dans", "que", "notre"),
+	},
+	"es": {
+		Lang: "es",
+		Name: "Español",
+		applicationQueries: []string{
+			`subject:"tu candidatura"`,
+			`subject:"su candidatura"`,
+			`subject:"tu solicitud"`,
+			`subject:"solicitud recibida"`,
+			`subject:"gracias por tu interés"`,
+			`subject:"gracias por postularte"`,
+		},
+		rejectionQueries: []string{
+			`subject:lamentablemente`,
+			`subject:"lamentamos"`,
+			`subject:"actualización de tu candidatura"`,
+		},
+		rejectionIndicators: []string{
+			"lamentablemente", "lamentamos", "sentimos informarte", "sentimos comunicarte",
+			"no ha sido seleccionad", "no has sido seleccionad", "otros candidatos", "otro candidato",
+			"no continuar con tu candidatura",
+		},
+		companyRes: []*regexp.Regexp{
+			regexp.MustCompile(`(?i)(?:tu|su) (?:candidatura|solicitud) (?:en|a|para) ([\p{L}\p{N}_\s\.\-&']+)`),
+		},
+		titleRes: []*regexp.Regexp{
+			regexp.MustCompile(`(?i)(?:puesto|vacante|posición) de ([\p{L}\p{N}_\s\-/&']+?)(?:\s+en\b|\s*[.,;:!]|$)`),
+		},
+		stopwords: wordSet("el", "la", "los", "las", "y", "tu", "su", "para", "por", "con",
+			"gracias", "hemos", "candidatura", "una", "del", "que", "nos", "en", "muy", "nuestro"),
+	},
+	"pt": {
+		Lang: "pt",
+		Name: "Português",
+		applicationQueries: []string{
+			`subject:"sua candidatura"`,
+			`subject:"a sua candidatura"`,
+			`subject:"candidatura recebida"`,
+			`subject:"recebemos sua candidatura"`,
+			`subject:"obrigado por se candidatar"`,
+		},
+		rejectionQueries: []string{
+			`subject:infelizmente`,
+			`subject:"atualização sobre sua candidatura"`,
+		},
+		rejectionIndicators: []string{
+			"infelizmente", "lamentamos", "não foi selecionad", "não seguiremos",
+			"outros candidatos", "outro candidato", "não avançar",
+		},
+		companyRes: []*regexp.Regexp{
+			regexp.MustCompile(`(?i)(?:sua|a sua) candidatura (?:na|no|para a|para o|à|ao) ([\p{L}\p{N}_\s\.\-&']+)`),
+		},
+		titleRes: []*regexp.Regexp{
+			regexp.MustCompile(`(?i)(?:vaga|cargo|posição) de ([\p{L}\p{N}_\s\-/&']+?)(?:\s+(?:na|no)\b|\s*[.,;:!]|$)`),
+		},
+		stopwords: wordSet("o", "os", "as", "e", "sua", "seu", "você", "para", "com", "não",
+			"obrigado", "obrigada", "recebemos", "candidatura", "uma", "do", "da", "que", "nós", "em"),
+	},
+	"nl": {
+		Lang: "nl",
+		Name: "Nederlands",
+		applicationQueries: []string{
+			`subject:"je sollicitatie"`,
+			`subject:"jouw sollicitatie"`,
+			`subject:"uw sollicitatie"`,
+			`subject:"sollicitatie ontvangen"`,
+			`subject:ontvangstbevestiging`,
+			`subject:"bedankt voor je sollicitatie"`,
+		},
+		rejectionQueries: []string{
+			`subject:helaas`,
+			`subject:afwijzing`,
+			`subject:"terugkoppeling sollicitatie"`,
+		},
+		rejectionIndicators: []string{
+			"helaas", "afwijzing", "niet verder", "andere kandidaten", "andere kandidaat",
+			"niet uitnodigen", "hebben besloten",
+		},
+		companyRes: []*regexp.Regexp{
+			regexp.MustCompile(`(?i)(?:je|jouw|uw) sollicitatie (?:bij|aan) ([\p{L}\p{N}_\s\.\-&']+)`),
+		},
+		titleRes: []*regexp.Regexp{
+			regexp.MustCompile(`(?i)sollicitatie (?:als|voor de functie van|voor) ([\p{L}\p{N}_\s\-/&']+?)(?:\s+bij\b|\s*[.,;:!]|$)`),
+		},
+		stopwords: wordSet("de", "het", "een", "en", "je", "jouw", "uw", "voor", "met", "wij",
+			"bedankt", "sollicitatie", "niet", "van", "bij", "ons", "graag", "zijn", "hebben", "dat"),
+	},
+}
+
+// PhrasePacks lists the available packs, English first.
+func PhrasePacks() []*PhrasePack {
+	out := []*PhrasePack{phrasePacks[DefaultLanguage]}
+	for _, lang := range []string{"de", "fr", "es", "pt", "nl"} {
+		out = append(out, phrasePacks[lang])
+	}
+	return out
+}
+
+// ValidLanguage reports whether a phrase pack exists for lang.
+func ValidLanguage(lang string) bool {
+	_, ok := phrasePacks[strings.ToLower(strings.TrimSpace(lang))]
+	return ok
+}
+
+func wordSet(words ...string) map[string]bool {
+	m := make(map[string]bool, len(words))
+	for _, w := range words {
+		m[w] = true
+	}
+	return m
+}
+
+// ---------- Selection ----------
+
+// Languages is the set of packs a scan uses. English is always included.
+type Languages []string
+
+// NewLanguages keeps known packs from a user's selection, English first.
+func NewLanguages(selected []string) Languages {
+	out := Languages{DefaultLanguage}
+	for _, l := range selected {
+		l = strings.ToLower(strings.TrimSpace(l))
+		if _, ok := phrasePacks[l]; ok && !out.has(l) {
+			out = append(out, l)
+		}
+	}
+	return out
+}
+
+func (ls Languages) has(lang string) bool {
+	for _, l := range ls {
+		if l == lang {
+			return true
+		}
+	}
+	return false
+}
+
+func (ls Languages) packs() []*PhrasePack {
+	if len(ls) == 0 {
+		return []*PhrasePack{phrasePacks[DefaultLanguage]}
+	}
+	out := make([]*PhrasePack, 0, len(ls))
+	for _, l := range ls {
+		if p, ok := phrasePacks[l]; ok {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func (ls Languages) applicationQueries() []string {
+	var qs []string
+	for _, p := range ls.packs() {
+		qs = append(qs, p.applicationQueries...)
+	}
+	return qs
+}
+
+func (ls Languages) rejectionQueries() []string {
+	var qs []string
+	for _, p := range ls.packs() {
+		qs = append(qs, p.rejectionQueries...)
+	}
+	return qs
+}
+
+// packFor picks the pack for a message: the detected language if the user
+// enabled it, English otherwise.
+func (ls Languages) packFor(subject, snippet string) *PhrasePack {
+	lang := DetectLanguage(subject + " " + snippet)
+	if !ls.has(lang) {
+		lang = DefaultLanguage
+	}
+	return phrasePacks[lang]
+}
+
+// ---------- Detection ----------
+
+// DetectLanguage guesses the language of a short text by counting the
+// function words of each pack. Ties and texts with no evidence count as
+// English.
+func DetectLanguage(text string) string {
+	best, bestHits := DefaultLanguage, 0
+	counts := map[string]int{}
+	for _, t := range reToken.FindAllString(strings.ToLower(text), -1) {
+		for lang, p := range phrasePacks {
+			if p.stopwords[t] {
+				counts[lang]++
+			}
+		}
+	}
+	for _, p := range PhrasePacks() {
+		if n := counts[p.Lang]; n > bestHits {
+			best, bestHits = p.Lang, n
+		}
+	}
+	return best
+}
+
+// ---------- Extraction ----------
+
+func (p *PhrasePack) isRejection(subject, snippet string) bool {
+	low := strings.ToLower(subject + " " + snippet)
+	for _, kw := range p.rejectionIndicators {
+		if strings.Contains(low, kw) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *PhrasePack) company(subject, from string) string {
+	s := strings.TrimSpace(subject)
+	if p.Lang != DefaultLanguage {
+		for _, re := range p.companyRes {
+			if m := re.FindStringSubmatch(s); len(m) > 1 {
+				return strings.TrimSpace(m[1])
+			}
+		}
+	}
+	return extractCompany(subject, from)
+}
+
+func (p *PhrasePack) title(subject, snippet string) string {
+	if p.Lang != DefaultLanguage {
+		for _, text := range []string{subject, snippet} {
+			for _, re := range p.titleRes {
+				if m := re.FindStringSubmatch(strings.TrimSpace(text)); len(m) > 1 {
+					return strings.TrimSpace(m[1])
+				}
+			}
+		}
+	}
+	// "Absage: ..." is not a job title.
+	if t := extractTitle(subject); !p.isRejection(t, "") {
+		return t
+	}
+	return ""
+}
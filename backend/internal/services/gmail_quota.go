@@ -0,0 +1,466 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gant123/jobTracker/internal/config"
+	"github.com/gant123/jobTracker/internal/models"
+	"github.com/gant123/jobTracker/internal/repository"
+)
+
+// ---------- Quota accounting ----------
+
+// Gmail's documented per-user limit is 250 quota units per second; the
+// project as a whole gets a daily allowance shared by every user.
+const (
+	defaultUserUnitsPerSec   = 250
+	defaultGlobalUnitsPerSec = 20000
+	defaultGlobalDailyUnits  = 1_000_000_000
+	// Concurrency bounds for one user's requests.
+	minConcurrency     = 1
+	maxConcurrency     = 16
+	initialConcurrency = 8
+	// How long a user's rate state is kept after their last request.
+	userIdleTTL = time.Hour
+)
+
+// QuotaExhaustedError means a daily budget is spent; work should resume
+// at RetryAt rather than retry now.
+type QuotaExhaustedError struct {
+	Scope   string // user|global
+	RetryAt time.Time
+}
+
+func (e *QuotaExhaustedError) Error() string {
+	return fmt.Sprintf("gmail %s quota exhausted until %s", e.Scope, e.RetryAt.Format(time.RFC3339))
+}
+
+// GmailQuota meters every Gmail API call made for a user against a
+// per-user and a global budget. Per-second rates are enforced by waiting,
+// which spreads a large sync over time; daily caps are enforced by
+// refusing with a QuotaExhaustedError. Concurrency per user adapts to
+// Gmail's 429s (additive increase, multiplicative decrease).
+type GmailQuota struct {
+	repo *repository.QuotaRepository
+
+	userRate    float64
+	userDaily   int64 // 0 means no per-user cap
+	globalDaily int64
+
+	mu      sync.Mutex
+	global  *tokenBucket
+	users   map[int]*userQuota
+	day     time.Time
+	today   int64 // global units used today, all users
+	pending map[int]*models.QuotaUsage
+}
+
+type userQuota struct {
+	bucket   *tokenBucket
+	limiter  *aimdLimiter
+	today    int64
+	lastUsed time.Time
+}
+
+func NewGmailQuota(repo *repository.QuotaRepository, cfg *config.Config) *GmailQuota {
+	return &GmailQuota{
+		repo:        repo,
+		userRate:    quotaLimit(cfg.GmailUserUnitsPerSec, defaultUserUnitsPerSec),
+		userDaily:   int64(quotaLimit(cfg.GmailUserDailyUnits, 0)),
+		globalDaily: int64(quotaLimit(cfg.GmailDailyUnits, defaultGlobalDailyUnits)),
+		global:      newTokenBucket(quotaLimit(cfg.GmailGlobalUnitsPerSec, defaultGlobalUnitsPerSec)),
+		users:       map[int]*userQuota{},
+		day:         utcDay(time.Now()),
+		pending:     map[int]*models.QuotaUsage{},
+	}
+}
+
+// quotaLimit parses a configured limit, falling back to def when it is
+// unset or invalid.
+func quotaLimit(v string, def float64) float64 {
+	if f, err := strconv.ParseFloat(v, 64); err == nil && f >= 0 {
+		return f
+	}
+	return def
+}
+
+func utcDay(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// user returns a user's state, loading today's usage on first sight so
+// daily caps survive restarts. It takes q.mu itself, and lets go of it
+// while reading the database so a slow query doesn't stall other users.
+func (q *GmailQuota) user(userID int) *userQuota {
+	q.mu.Lock()
+	q.rollover()
+	if u, ok := q.users[userID]; ok {
+		u.lastUsed = time.Now()
+		q.mu.Unlock()
+		return u
+	}
+	day := q.day
+	q.mu.Unlock()
+
+	used, global, err := q.repo.DayTotals(userID, day)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.rollover()
+	if u, ok := q.users[userID]; ok {
+		// Another request loaded it first.
+		u.lastUsed = time.Now()
+		return u
+	}
+	u := &userQuota{bucket: newTokenBucket(q.userRate), limiter: newAIMDLimiter(), lastUsed: time.Now()}
+	if err == nil && day.Equal(q.day) {
+		u.today = used
+		q.today = max(q.today, global)
+	}
+	q.users[userID] = u
+	return u
+}
+
+// evictIdle forgets users who haven't made a request in a while and have
+// nothing in flight or unflushed; they reload from the database next time.
+// Callers hold q.mu.
+func (q *GmailQuota) evictIdle() {
+	cutoff := time.Now().Add(-userIdleTTL)
+	for id, u := range q.users {
+		if _, pending := q.pending[id]; pending || u.lastUsed.After(cutoff) || u.limiter.busy() {
+			continue
+		}
+		delete(q.users, id)
+	}
+}
+
+// rollover starts a new day's counters at UTC midnight. Callers hold q.mu.
+func (q *GmailQuota) rollover() {
+	if day := utcDay(time.Now()); day.After(q.day) {
+		q.day = day
+		q.today = 0
+		for _, u := range q.users {
+			u.today = 0
+		}
+	}
+}
+
+// Reserve waits until units may be spent for userID, or fails if a daily
+// budget would be exceeded.
+func (q *GmailQuota) Reserve(ctx context.Context, userID int, units int64) error {
+	u := q.user(userID)
+	q.mu.Lock()
+	tomorrow := q.day.AddDate(0, 0, 1)
+	switch {
+	case q.userDaily > 0 && u.today+units > q.userDaily:
+		q.mu.Unlock()
+		return &QuotaExhaustedError{Scope: "user", RetryAt: tomorrow}
+	case q.globalDaily > 0 && q.today+units > q.globalDaily:
+		q.mu.Unlock()
+		return &QuotaExhaustedError{Scope: "global", RetryAt: tomorrow}
+	}
+	q.mu.Unlock()
+
+	if err := q.global.wait(ctx, units); err != nil {
+		return err
+	}
+	return u.bucket.wait(ctx, units)
+}
+
+// Record counts a request that was sent.
+func (q *GmailQuota) Record(userID int, units int64, throttled bool) {
+	u := q.user(userID)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	u.today += units
+	q.today += units
+
+	p, ok := q.pending[userID]
+	if !ok || !p.Day.Equal(q.day) {
+		if ok {
+			// Yesterday's leftovers flush under their own day.
+			q.flushOne(p)
+		}
+		p = &models.QuotaUsage{UserID: userID, Day: q.day}
+		q.pending[userID] = p
+	}
+	p.Units += units
+	p.Requests++
+	if throttled {
+		p.Throttled++
+	}
+}
+
+func (q *GmailQuota) limiter(userID int) *aimdLimiter {
+	return q.user(userID).limiter
+}
+
+// Flush writes pending usage to the database, then drops idle users.
+func (q *GmailQuota) Flush() {
+	q.mu.Lock()
+	pending := q.pending
+	q.pending = map[int]*models.QuotaUsage{}
+	q.mu.Unlock()
+
+	for _, p := range pending {
+		q.flushOne(p)
+	}
+
+	q.mu.Lock()
+	q.evictIdle()
+	q.mu.Unlock()
+}
+
+func (q *GmailQuota) flushOne(p *models.QuotaUsage) {
+	_ = q.repo.Add(p)
+}
+
+// Run flushes usage every interval until ctx is done. Requests still in
+// flight then are counted by a last Flush once they have finished.
+func (q *GmailQuota) Run(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			q.Flush()
+		}
+	}
+}
+
+// QuotaStatus is what a user sees of their quota.
+type QuotaStatus struct {
+	Today            int64                `json:"today_units"`
+	DailyLimit       int64                `json:"daily_limit,omitempty"`
+	UnitsPerSecond   float64              `json:"units_per_second"`
+	Concurrency      int                  `json:"concurrency"`
+	GlobalToday      int64                `json:"global_today_units"`
+	GlobalDailyLimit int64                `json:"global_daily_limit"`
+	History          []*models.QuotaUsage `json:"history"`
+}
+
+// Status reports a user's usage today and over the last week.
+func (q *GmailQuota) Status(userID int) (*QuotaStatus, error) {
+	q.Flush()
+	u := q.user(userID)
+	q.mu.Lock()
+	st := &QuotaStatus{
+		Today:            u.today,
+		DailyLimit:       q.userDaily,
+		UnitsPerSecond:   q.userRate,
+		Concurrency:      u.limiter.current(),
+		GlobalToday:      q.today,
+		GlobalDailyLimit: q.globalDaily,
+	}
+	q.mu.Unlock()
+
+	history, err := q.repo.UserHistory(userID, 7)
+	if err != nil {
+		return nil, err
+	}
+	st.History = history
+	return st, nil
+}
+
+// TopUsers lists who used the most quota today.
+func (q *GmailQuota) TopUsers(limit int) ([]*models.QuotaUsage, error) {
+	q.Flush()
+	return q.repo.TopUsers(utcDay(time.Now()), limit)
+}
+
+// ---------- Transport ----------
+
+type quotaUnitsKey struct{}
+
+// WithQuotaUnits overrides the quota cost of the request made with ctx,
+// for calls such as batches whose cost the URL doesn't show.
+func WithQuotaUnits(ctx context.Context, units int64) context.Context {
+	return context.WithValue(ctx, quotaUnitsKey{}, units)
+}
+
+// quotaUnits estimates a request's cost from Gmail's published per-method
+// quota units.
+func quotaUnits(req *http.Request) int64 {
+	if n, ok := req.Context().Value(quotaUnitsKey{}).(int64); ok {
+		return n
+	}
+	p := req.URL.Path
+	switch {
+	case strings.HasSuffix(p, "/profile"):
+		return 1
+	case strings.HasSuffix(p, "/history"):
+		return 2
+	case strings.Contains(p, "/threads/"):
+		return 10
+	case strings.HasSuffix(p, "/stop"):
+		return 50
+	case strings.HasSuffix(p, "/watch"):
+		return 100
+	default:
+		// messages.list, messages.get, attachments.get
+		return 5
+	}
+}
+
+// quotaTransport meters and paces the requests of one user's client.
+type quotaTransport struct {
+	base   http.RoundTripper
+	quota  *GmailQuota
+	userID int
+}
+
+func (t *quotaTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	units := quotaUnits(req)
+	if err := t.quota.Reserve(req.Context(), t.userID, units); err != nil {
+		return nil, err
+	}
+	lim := t.quota.limiter(t.userID)
+	if err := lim.acquire(req.Context()); err != nil {
+		return nil, err
+	}
+	resp, err := t.base.RoundTrip(req)
+	throttled := err == nil && resp.StatusCode == http.StatusTooManyRequests
+	lim.release(throttled)
+	t.quota.Record(t.userID, units, throttled)
+	return resp, err
+}
+
+// reportThrottle tells a client's limiter that Gmail pushed back inside a
+// response that was itself a success (a batch with 429 parts).
+func reportThrottle(c *http.Client) {
+	if t, ok := c.Transport.(*quotaTransport); ok {
+		t.quota.limiter(t.userID).throttled()
+	}
+}
+
+// ---------- Token bucket ----------
+
+// tokenBucket allows rate units per second with a one-second burst.
+// Waiters reserve ahead, so concurrent callers queue up in order instead
+// of all waking at once.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64) *tokenBucket {
+	return &tokenBucket{rate: rate, tokens: rate, last: time.Now()}
+}
+
+func (b *tokenBucket) wait(ctx context.Context, units int64) error {
+	if b.rate <= 0 {
+		return nil
+	}
+	b.mu.Lock()
+	now := time.Now()
+	b.tokens = min(b.rate, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	b.tokens -= float64(units)
+	var delay time.Duration
+	if b.tokens < 0 {
+		delay = time.Duration(-b.tokens / b.rate * float64(time.Second))
+	}
+	b.mu.Unlock()
+
+	if delay == 0 {
+		return nil
+	}
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		// The request won't be sent; give its reservation back.
+		b.mu.Lock()
+		b.tokens += float64(units)
+		b.mu.Unlock()
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// ---------- AIMD concurrency ----------
+
+// aimdLimiter caps in-flight requests. Each success raises the cap by
+// 1/cap (about +1 per round of requests); a 429 halves it, at most once a
+// second so one burst of 429s counts as one signal.
+type aimdLimiter struct {
+	mu        sync.Mutex
+	limit     float64
+	inFlight  int
+	lastDrop  time.Time
+	available chan struct{} // closed and replaced whenever a slot frees
+}
+
+func newAIMDLimiter() *aimdLimiter {
+	return &aimdLimiter{limit: initialConcurrency, available: make(chan struct{})}
+}
+
+func (l *aimdLimiter) acquire(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		if l.inFlight < int(l.limit) {
+			l.inFlight++
+			l.mu.Unlock()
+			return nil
+		}
+		ch := l.available
+		l.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ch:
+		}
+	}
+}
+
+func (l *aimdLimiter) release(throttled bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inFlight--
+	if throttled {
+		l.dropLocked()
+	} else {
+		l.limit = min(maxConcurrency, l.limit+1/l.limit)
+	}
+	close(l.available)
+	l.available = make(chan struct{})
+}
+
+func (l *aimdLimiter) throttled() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.dropLocked()
+}
+
+func (l *aimdLimiter) dropLocked() {
+	if time.Since(l.lastDrop) < time.Second {
+		return
+	}
+	l.limit = max(minConcurrency, l.limit/2)
+	l.lastDrop = time.Now()
+}
+
+func (l *aimdLimiter) busy() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inFlight > 0
+}
+
+func (l *aimdLimiter) current() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int(l.limit)
+}
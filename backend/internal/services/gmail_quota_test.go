@@ -0,0 +1,162 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestTokenBucketBurstAndRefill(t *testing.T) {
+	b := newTokenBucket(10)
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if err := b.wait(ctx, 5); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if b.tokens > 0.01 {
+		t.Fatalf("tokens after spending the burst = %.2f, want 0", b.tokens)
+	}
+
+	// A long idle period refills up to one second's worth, no more.
+	b.last = time.Now().Add(-time.Minute)
+	if err := b.wait(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(b.tokens-9) > 0.01 {
+		t.Errorf("tokens after idling = %.2f, want 9", b.tokens)
+	}
+}
+
+func TestTokenBucketWaits(t *testing.T) {
+	b := newTokenBucket(100)
+	ctx := context.Background()
+	if err := b.wait(ctx, 100); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if err := b.wait(ctx, 10); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < 50*time.Millisecond || d > time.Second {
+		t.Errorf("waited %s for 10 units at 100/s, want about 100ms", d)
+	}
+}
+
+func TestTokenBucketQueuesWaiters(t *testing.T) {
+	b := newTokenBucket(100)
+	if err := b.wait(context.Background(), 100); err != nil {
+		t.Fatal(err)
+	}
+	// Each waiter reserves its units up front, so the second one waits
+	// behind the first instead of racing it for the same refill.
+	start := time.Now()
+	done := make(chan time.Duration, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			if err := b.wait(context.Background(), 10); err != nil {
+				t.Error(err)
+			}
+			done <- time.Since(start)
+		}()
+	}
+	last := max(<-done, <-done)
+	if last < 150*time.Millisecond || last > 2*time.Second {
+		t.Errorf("second waiter done after %s, want about 200ms", last)
+	}
+}
+
+func TestTokenBucketCancelRefunds(t *testing.T) {
+	b := newTokenBucket(10)
+	if err := b.wait(context.Background(), 10); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := b.wait(ctx, 5); !errors.Is(err, context.Canceled) {
+		t.Fatalf("wait on an empty bucket = %v, want context.Canceled", err)
+	}
+	if b.tokens < -0.1 {
+		t.Errorf("tokens after a cancelled wait = %.2f, want the reservation given back", b.tokens)
+	}
+}
+
+func TestTokenBucketUnlimited(t *testing.T) {
+	b := newTokenBucket(0)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	for i := 0; i < 1000; i++ {
+		if err := b.wait(ctx, 1000); err != nil {
+			t.Fatalf("rate 0 waited: %v", err)
+		}
+	}
+}
+
+func TestAIMDLimiterCapsInFlight(t *testing.T) {
+	l := newAIMDLimiter()
+	ctx := context.Background()
+	for i := 0; i < initialConcurrency; i++ {
+		if err := l.acquire(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	short, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if err := l.acquire(short); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("acquire over the limit = %v, want context.DeadlineExceeded", err)
+	}
+
+	acquired := make(chan error, 1)
+	go func() { acquired <- l.acquire(ctx) }()
+	l.release(false)
+	select {
+	case err := <-acquired:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("waiter not woken by release")
+	}
+}
+
+func TestAIMDLimiterAdjusts(t *testing.T) {
+	l := newAIMDLimiter()
+	ctx := context.Background()
+	succeed := func(n int) {
+		for i := 0; i < n; i++ {
+			if err := l.acquire(ctx); err != nil {
+				t.Fatal(err)
+			}
+			l.release(false)
+		}
+	}
+
+	// A little under +1 per round of requests.
+	succeed(initialConcurrency + 1)
+	if got := l.current(); got != initialConcurrency+1 {
+		t.Errorf("after a round of successes: %d, want %d", got, initialConcurrency+1)
+	}
+	succeed(1000)
+	if got := l.current(); got != maxConcurrency {
+		t.Errorf("after many successes: %d, want the max %d", got, maxConcurrency)
+	}
+
+	// A 429 halves it, once per second however many arrive.
+	if err := l.acquire(ctx); err != nil {
+		t.Fatal(err)
+	}
+	l.release(true)
+	l.throttled()
+	if got := l.current(); got != maxConcurrency/2 {
+		t.Errorf("after a burst of 429s: %d, want %d", got, maxConcurrency/2)
+	}
+	for i := 0; i < 10; i++ {
+		l.lastDrop = time.Now().Add(-2 * time.Second)
+		l.throttled()
+	}
+	if got := l.current(); got != minConcurrency {
+		t.Errorf("after repeated 429s: %d, want the min %d", got, minConcurrency)
+	}
+}
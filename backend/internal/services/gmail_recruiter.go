@@ -0,0 +1,117 @@
+package services
+
+import (
+	"net/mail"
+	"regexp"
+	"strings"
+)
+
+// ---------- Recruiter outreach ----------
+
+var recruiterQueries = []string{
+	`"came across your profile"`,
+	`"came across your background"`,
+	`"your background caught my eye"`,
+	`"open to new opportunities"`,
+	`"would you be open to"`,
+	`"are you open to"`,
+	`"i'm a recruiter"`,
+	`"i am a recruiter"`,
+	`"talent acquisition"`,
+}
+
+var recruiterIndicators = []string{
+	"came across your profile", "came across your background", "your background caught",
+	"your experience caught", "open to new opportunities", "open to exploring",
+	"would you be open to", "are you open to", "would you be interested in",
+	"i'm a recruiter", "i am a recruiter", "i'm recruiting", "i am recruiting",
+	"talent acquisition", "reaching out about",
+}
+
+var (
+	// "... for a Senior Backend Engineer role ..." / "... as a Staff Engineer position"
+	// Only the keywords ignore case; the title must be capitalised words.
+	reOutreachRole = regexp.MustCompile(`\b(?i:for|as|about)\s+(?i:a|an|the|our)\s+((?:[A-Z][\w\+\#\./&-]*\s?){1,6}?)\s*(?i:role|position|opening|opportunity)\b`)
+	// "Senior Backend Engineer opportunity at Acme"
+	reOutreachRoleAt = regexp.MustCompile(`((?:[A-Z][\w\+\#\./&-]*\s?){1,6}?)\s*(?i:role|position|opportunity|opening)\s+(?i:at|with)\s+`)
+	// "... at Acme, ..." / "... with Acme." (capitalised names only)
+	reOutreachCompany = regexp.MustCompile(`\b(?:at|with|from|join|joining)\s+([A-Z][\w&'-]*(?:\s+[A-Z][\w&'-]*){0,3})`)
+)
+
+// Senders that are systems, not people; their mail is never personal outreach.
+var automatedSenders = []string{
+	"noreply", "no-reply", "donotreply", "do-not-reply", "notifications", "jobalerts",
+	"jobs-noreply", "mailer-daemon",
+}
+
+// classifyRecruiter recognises a recruiter writing to the user first and
+// turns it into a lead with the recruiter as its contact.
+func classifyRecruiter(m messageMeta) (EmailJobEvent, bool) {
+	low := strings.ToLower(m.Subject + " " + m.Snippet)
+	matched := false
+	for _, kw := range recruiterIndicators {
+		if strings.Contains(low, kw) {
+			matched = true
+			break
+		}
+	}
+	if !matched || reConfirmation.MatchString(m.Subject) {
+		return EmailJobEvent{}, false
+	}
+	for _, kw := range rejectionIndicators {
+		if strings.Contains(low, kw) {
+			return EmailJobEvent{}, false
+		}
+	}
+
+	from, err := mail.ParseAddress(m.From)
+	if err != nil {
+		return EmailJobEvent{}, false
+	}
+	addr := strings.ToLower(from.Address)
+	for _, a := range automatedSenders {
+		if strings.Contains(addr, a) {
+			return EmailJobEvent{}, false
+		}
+	}
+	for _, d := range digestSenders {
+		if strings.HasSuffix(addr, "@"+d) || strings.HasSuffix(addr, "."+d) {
+			return EmailJobEvent{}, false
+		}
+	}
+
+	text := m.Subject + ". " + m.Snippet
+	title := ""
+	if mm := reOutreachRole.FindStringSubmatch(text); len(mm) > 1 {
+		title = strings.TrimSpace(mm[1])
+	} else if mm := reOutreachRoleAt.FindStringSubmatch(text); len(mm) > 1 {
+		title = strings.TrimSpace(mm[1])
+	}
+	company := ""
+	if mm := reOutreachCompany.FindStringSubmatch(text); len(mm) > 1 {
+		company = strings.TrimRight(strings.TrimSpace(mm[1]), ".,")
+	}
+	if company == "" {
+		company = companyFromDomain(addr)
+	}
+
+	return EmailJobEvent{
+		MessageID:   m.ID,
+		ThreadID:    m.ThreadID,
+		Subject:     m.Subject,
+		From:        m.From,
+		Snippet:     m.Snippet,
+		Company:     company,
+		Title:       title,
+		Status:      "lead",
+		AppliedDate: m.Date,
+		Source:      "gmail",
+		Direction:   "inbound",
+		Link:        gmailLink(m.ID),
+		Contact: &EmailContact{
+			Name:  from.Name,
+			Email: addr,
+			Role:  "recruiter",
+		},
+	}, true
+}
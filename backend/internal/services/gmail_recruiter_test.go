@@ -0,0 +1,129 @@
+package services
+
+import "testing"
+
+func TestClassifyRecruiter(t *testing.T) {
+	tests := []struct {
+		name                   string
+		subject, snippet, from string
+		ok                     bool
+		title, company         string
+		contact                string
+	}{
+		{
+			name:    "role and company",
+			subject: "Senior Backend Engineer opportunity at Acme",
+			snippet: "Hi Sam, I came across your profile and thought you'd be a great fit.",
+			from:    "Jane Doe <jane@talent.example.com>",
+			ok:      true, title: "Senior Backend Engineer", company: "Acme", contact: "jane@talent.example.com",
+		},
+		{
+			name:    "role after for a",
+			subject: "Quick question",
+			snippet: "I'm a recruiter at Globex and I'm reaching out about our search for a Staff Platform Engineer role.",
+			from:    "Bob Smith <bob@globex.com>",
+			ok:      true, title: "Staff Platform Engineer", company: "Globex", contact: "bob@globex.com",
+		},
+		{
+			name:    "capitalised keywords",
+			subject: "Role",
+			snippet: "Would you be open to chatting About a Lead Data Engineer Position? We are a team at Initech.",
+			from:    "Ann <ann@initech.io>",
+			ok:      true, title: "Lead Data Engineer", company: "Initech", contact: "ann@initech.io",
+		},
+		{
+			name:    "lowercase words aren't a title",
+			subject: "Hello",
+			snippet: "Are you open to hearing about a great new opportunity? I came across your profile.",
+			from:    "Recruiter <kim@hooli.com>",
+			ok:      true, title: "", company: "Hooli", contact: "kim@hooli.com",
+		},
+		{
+			name:    "company from domain",
+			subject: "Your background caught my eye",
+			snippet: "would you be interested in a chat next week?",
+			from:    "Lee <lee@umbrella.co.uk>",
+			ok:      true, company: "Umbrella", contact: "lee@umbrella.co.uk",
+		},
+		{
+			name:    "free mail recruiter",
+			subject: "Open to new opportunities?",
+			snippet: "i am recruiting for a few startups",
+			from:    "Pat <pat.recruits@gmail.com>",
+			ok:      true, company: "", contact: "pat.recruits@gmail.com",
+		},
+		{
+			name:    "automated sender",
+			subject: "Are you open to new roles?",
+			snippet: "Talent acquisition teams are looking at your profile.",
+			from:    "Acme <no-reply@acme.com>",
+		},
+		{
+			name:    "notifications sender",
+			subject: "Someone came across your profile",
+			from:    "notifications@acme.com",
+		},
+		{
+			name:    "digest sender",
+			subject: "Would you be open to these jobs?",
+			snippet: "We came across your profile",
+			from:    "LinkedIn <jobs-listings@linkedin.com>",
+		},
+		{
+			name:    "job board subdomain",
+			subject: "Are you open to a new role?",
+			from:    "Indeed <alert@match.indeed.com>",
+		},
+		{
+			name:    "rejection",
+			subject: "Your application at Acme",
+			snippet: "Unfortunately we are not moving forward, but we'd be open to exploring future roles.",
+			from:    "jane@acme.com",
+		},
+		{
+			name:    "regret wording",
+			subject: "Talent acquisition update",
+			snippet: "We regret to inform you that the position has been filled.",
+			from:    "hr@acme.com",
+		},
+		{
+			name:    "confirmation",
+			subject: "Thank you for applying to Acme",
+			snippet: "Our talent acquisition team will review your application.",
+			from:    "jane@acme.com",
+		},
+		{
+			name:    "generic excitement isn't outreach",
+			subject: "An exciting opportunity awaits",
+			snippet: "Let's have a quick chat about our new product launch.",
+			from:    "marketing@saas.example.com",
+		},
+		{
+			name:    "unparseable sender",
+			subject: "I came across your profile",
+			from:    "not an address",
+		},
+	}
+	for _, tt := range tests {
+		ev, ok := classifyRecruiter(messageMeta{ID: "m1", Subject: tt.subject, Snippet: tt.snippet, From: tt.from})
+		if ok != tt.ok {
+			t.Errorf("%s: ok = %v, want %v", tt.name, ok, tt.ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if ev.Status != "lead" || ev.Direction != "inbound" {
+			t.Errorf("%s: status/direction = %q/%q, want lead/inbound", tt.name, ev.Status, ev.Direction)
+		}
+		if ev.Title != tt.title {
+			t.Errorf("%s: title = %q, want %q", tt.name, ev.Title, tt.title)
+		}
+		if ev.Company != tt.company {
+			t.Errorf("%s: company = %q, want %q", tt.name, ev.Company, tt.company)
+		}
+		if ev.Contact == nil || ev.Contact.Email != tt.contact || ev.Contact.Role != "recruiter" {
+			t.Errorf("%s: contact = %+v, want recruiter %s", tt.name, ev.Contact, tt.contact)
+		}
+	}
+}
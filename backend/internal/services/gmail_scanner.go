@@ -17,21 +17,90 @@ import (
 // ---------- Types ----------
 
 type EmailJobEvent struct {
-	MessageID   string    `json:"messageId"`
-	Subject     string    `json:"subject"`
-	Snippet     string    `json:"snippet"`
-	Company     string    `json:"company,omitempty"`
-	Title       string    `json:"title,omitempty"`
-	Status      string    `json:"status"` // wishlist|applied|interviewing|offer|rejected|withdrawn
+	MessageID string `json:"messageId"`
+	ThreadID  string `json:"threadId,omitempty"`
+	Subject   string `json:"subject"`
+	From      string `json:"from,omitempty"`
+	Snippet   string `json:"snippet"`
+	Company   string `json:"company,omitempty"`
+	Title     string `json:"title,omitempty"`
+	Location  string `json:"location,omitempty"`
+	URL       string `json:"url,omitempty"` // posting link, for digest leads
+	// SourceKey tells apart several jobs taken from the same message
+	// (one per posting in an alert digest). Empty for one-job messages.
+	SourceKey   string    `json:"sourceKey,omitempty"`
+	Status      string    `json:"status"` // lead|wishlist|applied|interviewing|offer|rejected|withdrawn
 	AppliedDate time.Time `json:"appliedDate,omitempty"`
-	Source      string    `json:"source"`         // gmail
+	Source      string    `json:"source"`    // gmail
+	Direction   string    `json:"direction"` // inbound|outbound
+	Language    string    `json:"language,omitempty"`
 	Link        string    `json:"link,omitempty"` // direct gmail link
+	// Contact is the person on the other end, when we know who it is
+	// (e.g. the recipient of an application sent directly).
+	Contact *EmailContact `json:"contact,omitempty"`
+	// Salary is the pay range the message or posting states, if any.
+	Salary *Salary `json:"salary,omitempty"`
+	// What the user's learned model thought, when it has an opinion.
+	ModelStatus     string  `json:"modelStatus,omitempty"`
+	ModelConfidence float64 `json:"modelConfidence,omitempty"`
+}
+
+type EmailContact struct {
+	Name  string `json:"name,omitempty"`
+	Email string `json:"email"`
+	Role  string `json:"role,omitempty"`
 }
 
 type GmailScanner struct{}
 
 func NewGmailScanner() *GmailScanner { return &GmailScanner{} }
 
+// ScanProfile carries the per-user state that shapes a scan. A nil profile
+// scans with the built-in rules only.
+type ScanProfile struct {
+	Model     *NaiveBayes
+	Senders   *SenderRules
+	Dismissed map[string]struct{} // messages the user marked "not a job"
+	Languages Languages           // phrase packs to search and classify with
+	Cache     *MessageCache
+}
+
+func (p *ScanProfile) model() *NaiveBayes {
+	if p == nil {
+		return nil
+	}
+	return p.Model
+}
+
+func (p *ScanProfile) senders() *SenderRules {
+	if p == nil {
+		return nil
+	}
+	return p.Senders
+}
+
+func (p *ScanProfile) languages() Languages {
+	if p == nil {
+		return nil
+	}
+	return p.Languages
+}
+
+func (p *ScanProfile) cache() *MessageCache {
+	if p == nil {
+		return nil
+	}
+	return p.Cache
+}
+
+func (p *ScanProfile) dismissed(id string) bool {
+	if p == nil {
+		return false
+	}
+	_, ok := p.Dismissed[id]
+	return ok
+}
+
 type ScanResult struct {
 	Events        []EmailJobEvent `json:"events"`
 	NextPageToken string          `json:"nextPageToken,omitempty"`
@@ -68,6 +137,10 @@ func joined(qs []string) string { return "(" + strings.Join(qs, " OR ") + ")" }
 
 // ---------- Extractors ----------
 
+// ExtractorVersion changes whenever the rule-based extractors change, so
+// verdicts cached under older rules are recomputed.
+const ExtractorVersion = 2
+
 var (
 	reAtCompany = regexp.MustCompile(`(?i)\bat\s+([A-Za-z0-9&\.\-'\s]+)`)
 	companyRes  = []*regexp.Regexp{
@@ -131,7 +204,7 @@ func extractTitle(subject string) string {
 
 // ---------- Paged Scan ----------
 
-// only: "all" | "applied" | "rejected"
+// only: "all" | "applied" | "rejected" | "sent" | "leads"
 // internal/services/gmail_scanner.go
 
 // ... (keep everything above this function the same)
@@ -280,7 +353,10 @@ func extractTitle(subject string) string {
 //
 //		return ScanResult{Events: filteredEvents, NextPageToken: res.NextPageToken}, nil
 //	}
-func (s *GmailScanner) ScanPage(ctx context.Context, srv *gmail.Service, since, until time.Time, max int64, pageToken, only string, existingIDs map[string]struct{}) (ScanResult, error) {
+
+// ScanPage lists one page of candidate messages and classifies the new
+// ones. Metadata is fetched through fetcher, a page at a time.
+func (s *GmailScanner) ScanPage(ctx context.Context, srv *gmail.Service, fetcher MessageFetcher, since, until time.Time, max int64, pageToken, only string, existingIDs map[string]struct{}, profile *ScanProfile) (ScanResult, error) {
 	if max <= 0 {
 		max = 200
 	}
@@ -296,17 +372,30 @@ func (s *GmailScanner) ScanPage(ctx context.Context, srv *gmail.Service, since,
 		dateFilter += fmt.Sprintf(" before:%s", until.AddDate(0, 0, 1).UTC().Format("2006/01/02"))
 	}
 
+	mode := strings.ToLower(strings.TrimSpace(only))
+	langs := profile.languages()
 	var q string
-	switch strings.ToLower(strings.TrimSpace(only)) {
+	switch mode {
 	case "rejected":
-		q = joined(rejectionQueries)
+		q = joined(langs.rejectionQueries())
 	case "applied":
-		q = joined(applicationQueries)
+		q = joined(langs.applicationQueries())
+	case "sent":
+		q = "in:sent " + joined(sentQueries)
+	case "leads":
+		q = joined(recruiterQueries)
 	default:
-		q = joined(applicationQueries) + " OR " + joined(rejectionQueries)
+		q = joined(langs.applicationQueries()) + " OR " + joined(langs.rejectionQueries()) + " OR " + joined(recruiterQueries)
+	}
+	if mode != "sent" {
+		q = profile.senders().applyToQuery(q)
 	}
 	q = q + dateFilter
 
+	// To is only read for sent mail, but fetching it always lets one cached
+	// copy of the headers serve every mode.
+	headers := []string{"Subject", "Date", "From", "To"}
+
 	list := srv.Users.Messages.List("me").Q(q).MaxResults(max)
 	if pageToken != "" {
 		list.PageToken(pageToken)
@@ -316,93 +405,214 @@ func (s *GmailScanner) ScanPage(ctx context.Context, srv *gmail.Service, since,
 		return ScanResult{}, err
 	}
 
+	ids := make([]string, 0, len(res.Messages))
+	for _, m := range res.Messages {
+		// Skip what's already a job, or what the user said isn't one.
+		if _, exists := existingIDs[m.Id]; exists || profile.dismissed(m.Id) {
+			continue
+		}
+		ids = append(ids, m.Id)
+	}
+
+	// Headers come from the cache when an earlier scan fetched them; the
+	// rest are fetched together.
+	cached := profile.cache().lookup(ids)
+	var missing []string
+	for _, id := range ids {
+		if cached[id] == nil {
+			missing = append(missing, id)
+		}
+	}
+	fetched := newFetchResult()
+	if len(missing) > 0 {
+		fetched, err = fetcher.Metadata(ctx, missing, headers)
+		if err != nil {
+			return ScanResult{}, err
+		}
+	}
+	version := profile.version()
+
 	type one struct {
-		ev  EmailJobEvent
-		ok  bool
+		evs []EmailJobEvent
 		err error
 	}
-	ch := make(chan one, len(res.Messages))
+	ch := make(chan one, len(ids))
 	var wg sync.WaitGroup
 	sem := make(chan struct{}, 16)
 
-	for _, m := range res.Messages {
+	for _, id := range ids {
 		wg.Add(1)
 		go func(id string) {
 			defer wg.Done()
 			sem <- struct{}{}
 			defer func() { <-sem }()
 
-			// FIRST: Check if we already have this ID before making an API call.
-			if _, exists := existingIDs[id]; exists {
-				ch <- one{ok: false} // Send a signal to skip this one.
+			var meta messageMeta
+			entry := cached[id]
+			switch {
+			case entry != nil:
+				meta = metaFromCache(entry)
+			case fetched.Messages[id] != nil:
+				meta = parseMessageMeta(fetched.Messages[id])
+			default:
+				ch <- one{err: fetched.Errors[id]}
 				return
 			}
 
-			// If it's a new ID, proceed to fetch its details.
-			msg, err := srv.Users.Messages.Get("me", id).
-				Format("metadata").
-				MetadataHeaders("Subject", "Date", "From").
-				Do()
-			if err != nil {
-				ch <- one{err: err}
+			if mode != "sent" && profile.senders().Blocked(meta.From) {
+				ch <- one{}
 				return
 			}
 
-			// ... (rest of the message parsing logic remains the same)
-			var subj, from, dateStr string
-			for _, h := range msg.Payload.Headers {
-				switch h.Name {
-				case "Subject":
-					subj = h.Value
-				case "From":
-					from = h.Value
-				case "Date":
-					dateStr = h.Value
-				}
-			}
-
-			var applied time.Time
-			if msg.InternalDate > 0 {
-				applied = time.UnixMilli(msg.InternalDate)
-			} else if dateStr != "" {
-				if t, e := mail.ParseDate(dateStr); e == nil {
-					applied = t
+			// Reuse the cached verdict if it was reached the same way.
+			evs, class, ok := cachedVerdict(entry, version, mode == "sent")
+			if !ok {
+				var err error
+				evs, class, err = classifyFetched(ctx, srv, meta, mode == "sent", profile)
+				if err != nil {
+					ch <- one{err: err}
+					return
 				}
+				profile.cache().store(meta, version, class, evs)
 			}
-
-			ev := EmailJobEvent{
-				MessageID:   msg.Id,
-				Subject:     subj,
-				Snippet:     msg.Snippet,
-				Company:     extractCompany(subj, from),
-				Title:       extractTitle(subj),
-				Status:      "applied",
-				AppliedDate: applied,
-				Source:      "gmail",
-				Link:        "https://mail.google.com/mail/u/0/#all/" + msg.Id,
-			}
-
-			low := strings.ToLower(subj + " " + msg.Snippet)
-			for _, kw := range rejectionIndicators {
-				if strings.Contains(low, kw) {
-					ev.Status = "rejected"
-					break
-				}
+			if mode == "leads" && class == classInbound {
+				ch <- one{}
+				return
 			}
-			ch <- one{ev: ev, ok: true}
-		}(m.Id)
+			ch <- one{evs: evs}
+		}(id)
 	}
 
 	wg.Wait()
 	close(ch)
 
 	out := make([]EmailJobEvent, 0, len(res.Messages))
+	var fatal error
 	for x := range ch {
-		if x.ok {
-			out = append(out, x.ev)
+		if fatal == nil && fatalFetchError(x.err) {
+			fatal = x.err
 		}
+		out = append(out, x.evs...)
+	}
+	if fatal != nil {
+		// Skipping these messages would lose them; the caller retries the
+		// page once quota or the grant is back.
+		return ScanResult{}, fatal
 	}
 
 	sort.SliceStable(out, func(i, j int) bool { return out[i].AppliedDate.After(out[j].AppliedDate) })
 	return ScanResult{Events: out, NextPageToken: res.NextPageToken}, nil
 }
+
+// messageMeta is the subset of a Gmail message the classifiers look at.
+type messageMeta struct {
+	ID       string
+	ThreadID string
+	Subject  string
+	From     string
+	To       string
+	Snippet  string
+	Date     time.Time
+}
+
+func parseMessageMeta(msg *gmail.Message) messageMeta {
+	meta := messageMeta{ID: msg.Id, ThreadID: msg.ThreadId, Snippet: msg.Snippet}
+	var dateStr string
+	if msg.Payload != nil {
+		for _, h := range msg.Payload.Headers {
+			switch h.Name {
+			case "Subject":
+				meta.Subject = h.Value
+			case "From":
+				meta.From = h.Value
+			case "To":
+				meta.To = h.Value
+			case "Date":
+				dateStr = h.Value
+			}
+		}
+	}
+
+	// Prefer InternalDate; fallback to parsed Date header
+	if msg.InternalDate > 0 {
+		meta.Date = time.UnixMilli(msg.InternalDate)
+	} else if dateStr != "" {
+		if t, e := mail.ParseDate(dateStr); e == nil {
+			meta.Date = t
+		}
+	}
+	return meta
+}
+
+func gmailLink(id string) string { return "https://mail.google.com/mail/u/0/#all/" + id }
+
+// Which path read a message; cached with the verdict.
+const (
+	classSent    = "sent"
+	classDigest  = "digest"
+	classLead    = "lead"
+	classInbound = "inbound"
+)
+
+// classifyFetched turns a message's headers into job events. Digests cost
+// one more request for their body.
+func classifyFetched(ctx context.Context, srv *gmail.Service, meta messageMeta, sent bool, profile *ScanProfile) ([]EmailJobEvent, string, error) {
+	switch {
+	case sent:
+		if ev, ok := classifySent(meta); ok {
+			return []EmailJobEvent{ev}, classSent, nil
+		}
+		return nil, classSent, nil
+	case isDigestCandidate(meta):
+		// One alert, many postings; never an application.
+		evs, err := fetchDigestEvents(ctx, srv, meta)
+		return evs, classDigest, err
+	default:
+		ev, lead := classifyMessage(meta, profile)
+		if lead {
+			return []EmailJobEvent{ev}, classLead, nil
+		}
+		return []EmailJobEvent{ev}, classInbound, nil
+	}
+}
+
+// classifyMessage reads a single-job inbound message: recruiter outreach
+// if it looks like one, an application confirmation or rejection
+// otherwise, with the user's model having the last word. lead reports
+// whether it was recruiter outreach.
+func classifyMessage(m messageMeta, profile *ScanProfile) (ev EmailJobEvent, lead bool) {
+	ev, lead = classifyRecruiter(m)
+	if !lead {
+		ev = classifyInbound(m, profile.languages().packFor(m.Subject, m.Snippet))
+	}
+	// Recruiters and pay-transparency confirmations often quote a range.
+	if sal, ok := ExtractSalary(m.Subject + "\n" + m.Snippet); ok {
+		ev.Salary = &sal
+	}
+	applyModel(profile.model(), &ev)
+	return ev, lead
+}
+
+// classifyInbound turns an ATS confirmation or rejection into an event,
+// reading it with the phrase pack for its language.
+func classifyInbound(m messageMeta, pack *PhrasePack) EmailJobEvent {
+	ev := EmailJobEvent{
+		MessageID:   m.ID,
+		ThreadID:    m.ThreadID,
+		Subject:     m.Subject,
+		From:        m.From,
+		Snippet:     m.Snippet,
+		Company:     pack.company(m.Subject, m.From),
+		Title:       pack.title(m.Subject, m.Snippet),
+		Status:      "applied",
+		AppliedDate: m.Date,
+		Source:      "gmail",
+		Direction:   "inbound",
+		Language:    pack.Lang,
+		Link:        gmailLink(m.ID),
+	}
+	if pack.isRejection(m.Subject, m.Snippet) {
+		ev.Status = "rejected"
+	}
+	return ev
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"net/mail"
+	"regexp"
+	"strings"
+)
+
+// ---------- Sent folder ----------
+
+// sentQueries find applications the user emailed directly to a recruiter
+// or hiring manager: a resume attached, or the usual application phrasing.
+var sentQueries = []string{
+	`has:attachment (filename:pdf OR filename:doc OR filename:docx) (resume OR cv OR "cover letter")`,
+	`subject:"application for"`,
+	`subject:"applying for"`,
+	`subject:resume`,
+	`"please find attached my resume"`,
+	`"attached is my resume"`,
+	`"attached my resume"`,
+	`"i would like to apply"`,
+	`"i am writing to apply"`,
+	`"interested in the position"`,
+}
+
+var sentIndicators = []string{
+	"resume", "résumé", "cv", "cover letter", "apply", "applying", "application",
+	"interested in the position", "interested in the role",
+}
+
+// Personal mailbox domains say nothing about the employer.
+var freeMailDomains = map[string]bool{
+	"gmail.com": true, "googlemail.com": true, "outlook.com": true, "hotmail.com": true,
+	"live.com": true, "msn.com": true, "yahoo.com": true, "icloud.com": true,
+	"me.com": true, "aol.com": true, "proton.me": true, "protonmail.com": true,
+	"gmx.com": true, "gmx.de": true, "mail.com": true, "zoho.com": true,
+}
+
+// Second-level suffixes where the company label sits one further left.
+var compoundTLDs = map[string]bool{
+	"co.uk": true, "ac.uk": true, "org.uk": true, "com.au": true, "co.nz": true,
+	"co.jp": true, "com.br": true, "co.in": true, "com.mx": true, "co.za": true,
+}
+
+var reSentTitle = regexp.MustCompile(`(?i)\b(?:application|applying)\s+(?:for|to)\s+(?:the\s+)?([\w\s\.\-/&']+?)(?:\s+(?:position|role|opening))?(?:\s+(?:at|with)\b.*)?$`)
+
+// classifySent turns an outbound application email into an "applied" event
+// addressed to the first recipient with a company domain.
+func classifySent(m messageMeta) (EmailJobEvent, bool) {
+	low := strings.ToLower(m.Subject + " " + m.Snippet)
+	matched := false
+	for _, kw := range sentIndicators {
+		if strings.Contains(low, kw) {
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		return EmailJobEvent{}, false
+	}
+
+	addrs, err := mail.ParseAddressList(m.To)
+	if err != nil || len(addrs) == 0 {
+		return EmailJobEvent{}, false
+	}
+	recipient := addrs[0]
+	company := ""
+	for _, a := range addrs {
+		if c := companyFromDomain(a.Address); c != "" {
+			recipient, company = a, c
+			break
+		}
+	}
+
+	title := extractTitle(m.Subject)
+	if mm := reSentTitle.FindStringSubmatch(strings.TrimSpace(m.Subject)); len(mm) > 1 {
+		title = strings.TrimSpace(mm[1])
+	}
+	if c := extractCompany(m.Subject, ""); c != "" && company == "" {
+		company = c
+	}
+
+	return EmailJobEvent{
+		MessageID:   m.ID,
+		ThreadID:    m.ThreadID,
+		Subject:     m.Subject,
+		From:        m.From,
+		Snippet:     m.Snippet,
+		Company:     company,
+		Title:       title,
+		Status:      "applied",
+		AppliedDate: m.Date,
+		Source:      "gmail",
+		Direction:   "outbound",
+		Link:        gmailLink(m.ID),
+		Contact: &EmailContact{
+			Name:  recipient.Name,
+			Email: strings.ToLower(recipient.Address),
+			Role:  "recipient",
+		},
+	}, true
+}
+
+// companyFromDomain guesses an employer from an email address:
+// "jane@careers.acme.co.uk" -> "Acme". Free-mail domains yield "".
+func companyFromDomain(addr string) string {
+	i := strings.LastIndex(addr, "@")
+	if i == -1 {
+		return ""
+	}
+	domain := strings.ToLower(strings.TrimSpace(addr[i+1:]))
+	if domain == "" || freeMailDomains[domain] {
+		return ""
+	}
+	labels := strings.Split(domain, ".")
+	if len(labels) < 2 {
+		return ""
+	}
+	idx := len(labels) - 2
+	if len(labels) >= 3 && compoundTLDs[strings.Join(labels[len(labels)-2:], ".")] {
+		idx = len(labels) - 3
+	}
+	return titler.String(labels[idx])
+}
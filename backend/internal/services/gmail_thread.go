@@ -0,0 +1,333 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/mail"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/html"
+	gmail "google.golang.org/api/gmail/v1"
+)
+
+// ---------- Types ----------
+
+type EmailThread struct {
+	ID       string          `json:"id"`
+	Messages []ThreadMessage `json:"messages"`
+}
+
+type ThreadMessage struct {
+	ID          string             `json:"id"`
+	From        string             `json:"from"`
+	To          string             `json:"to,omitempty"`
+	Cc          string             `json:"cc,omitempty"`
+	Subject     string             `json:"subject"`
+	Date        time.Time          `json:"date"`
+	Text        string             `json:"text,omitempty"`
+	HTML        string             `json:"html,omitempty"` // sanitized
+	Attachments []ThreadAttachment `json:"attachments,omitempty"`
+	Link        string             `json:"link"`
+}
+
+type ThreadAttachment struct {
+	AttachmentID string `json:"attachment_id"`
+	Filename     string `json:"filename"`
+	MimeType     string `json:"mime_type"`
+	Size         int64  `json:"size"`
+}
+
+// ---------- Fetch ----------
+
+// ThreadIDForMessage looks up the thread a message belongs to.
+func ThreadIDForMessage(ctx context.Context, srv *gmail.Service, messageID string) (string, error) {
+	msg, err := srv.Users.Messages.Get("me", messageID).Format("minimal").Context(ctx).Do()
+	if err != nil {
+		return "", err
+	}
+	return msg.ThreadId, nil
+}
+
+// FetchThread downloads a whole thread and flattens every message into
+// plain text, sanitized HTML and attachment metadata.
+func FetchThread(ctx context.Context, srv *gmail.Service, threadID string) (*EmailThread, error) {
+	th, err := srv.Users.Threads.Get("me", threadID).Format("full").Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch thread: %w", err)
+	}
+
+	out := &EmailThread{ID: th.Id, Messages: make([]ThreadMessage, 0, len(th.Messages))}
+	for _, msg := range th.Messages {
+		tm := ThreadMessage{
+			ID:   msg.Id,
+			Link: "https://mail.google.com/mail/u/0/#all/" + msg.Id,
+		}
+		if msg.InternalDate > 0 {
+			tm.Date = time.UnixMilli(msg.InternalDate)
+		}
+		if msg.Payload != nil {
+			for _, h := range msg.Payload.Headers {
+				switch h.Name {
+				case "From":
+					tm.From = h.Value
+				case "To":
+					tm.To = h.Value
+				case "Cc":
+					tm.Cc = h.Value
+				case "Subject":
+					tm.Subject = h.Value
+				case "Date":
+					if tm.Date.IsZero() {
+						if t, e := mail.ParseDate(h.Value); e == nil {
+							tm.Date = t
+						}
+					}
+				}
+			}
+
+			var text, rawHTML string
+			walkParts(msg.Payload, &text, &rawHTML, &tm.Attachments)
+			if rawHTML != "" {
+				tm.HTML = SanitizeHTML(rawHTML)
+			}
+			if text == "" && rawHTML != "" {
+				text = HTMLToText(rawHTML)
+			}
+			tm.Text = strings.TrimSpace(text)
+		}
+		out.Messages = append(out.Messages, tm)
+	}
+	return out, nil
+}
+
+// walkParts collects the first text/plain and text/html bodies and every
+// attachment in a MIME tree.
+func walkParts(p *gmail.MessagePart, text, rawHTML *string, atts *[]ThreadAttachment) {
+	if p == nil {
+		return
+	}
+	if p.Filename != "" && p.Body != nil {
+		*atts = append(*atts, ThreadAttachment{
+			AttachmentID: p.Body.AttachmentId,
+			Filename:     p.Filename,
+			MimeType:     p.MimeType,
+			Size:         p.Body.Size,
+		})
+		return
+	}
+	switch {
+	case p.MimeType == "text/plain" && *text == "":
+		*text = decodeBody(p.Body)
+	case p.MimeType == "text/html" && *rawHTML == "":
+		*rawHTML = decodeBody(p.Body)
+	}
+	for _, child := range p.Parts {
+		walkParts(child, text, rawHTML, atts)
+	}
+}
+
+func decodeBody(b *gmail.MessagePartBody) string {
+	if b == nil || b.Data == "" {
+		return ""
+	}
+	data, err := base64.URLEncoding.DecodeString(b.Data)
+	if err != nil {
+		data, err = base64.RawURLEncoding.DecodeString(b.Data)
+		if err != nil {
+			return ""
+		}
+	}
+	return string(data)
+}
+
+// ---------- Sanitizing ----------
+
+// Tags we keep (without attributes, except href on links). Everything else
+// is unwrapped to its text; the dropped set loses its content too.
+var (
+	allowedTags = map[string]bool{
+		"a": true, "b": true, "blockquote": true, "br": true, "code": true,
+		"div": true, "em": true, "h1": true, "h2": true, "h3": true, "h4": true,
+		"h5": true, "h6": true, "hr": true, "i": true, "li": true, "ol": true,
+		"p": true, "pre": true, "span": true, "strong": true, "table": true,
+		"tbody": true, "td": true, "th": true, "thead": true, "tr": true,
+		"u": true, "ul": true,
+	}
+	droppedTags = map[string]bool{
+		"script": true, "style": true, "head": true, "title": true,
+		"iframe": true, "object": true, "embed": true, "noscript": true,
+		"template": true, "svg": true, "math": true, "form": true,
+	}
+	voidTags = map[string]bool{"br": true, "hr": true}
+)
+
+// SanitizeHTML reduces an email body to a small allowlist of formatting
+// tags. Images, styles, scripts and event handlers are removed, and links
+// are limited to http(s) and mailto. Tags left open are closed and stray
+// end tags dropped, so the body can't reach into the page around it.
+func SanitizeHTML(in string) string {
+	var b strings.Builder
+	z := html.NewTokenizer(strings.NewReader(in))
+	skipDepth := 0
+	var open []string
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			for i := len(open) - 1; i >= 0; i-- {
+				b.WriteString("</" + open[i] + ">")
+			}
+			return b.String()
+		case html.TextToken:
+			if skipDepth == 0 {
+				b.WriteString(html.EscapeString(string(z.Text())))
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tok := z.Token()
+			if droppedTags[tok.Data] {
+				if tt == html.StartTagToken {
+					skipDepth++
+				}
+				continue
+			}
+			if skipDepth > 0 || !allowedTags[tok.Data] {
+				continue
+			}
+			b.WriteString("<" + tok.Data)
+			if tok.Data == "a" {
+				for _, a := range tok.Attr {
+					if a.Key == "href" && safeHref(a.Val) {
+						b.WriteString(` href="` + html.EscapeString(a.Val) + `" rel="noopener noreferrer" target="_blank"`)
+						break
+					}
+				}
+			}
+			b.WriteString(">")
+			if !voidTags[tok.Data] && tt == html.StartTagToken {
+				open = append(open, tok.Data)
+			} else if !voidTags[tok.Data] {
+				b.WriteString("</" + tok.Data + ">")
+			}
+		case html.EndTagToken:
+			tok := z.Token()
+			if droppedTags[tok.Data] {
+				if skipDepth > 0 {
+					skipDepth--
+				}
+				continue
+			}
+			if skipDepth > 0 || !allowedTags[tok.Data] || voidTags[tok.Data] {
+				continue
+			}
+			// Close back to the matching open tag, if there is one.
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i] != tok.Data {
+					continue
+				}
+				for j := len(open) - 1; j >= i; j-- {
+					b.WriteString("</" + open[j] + ">")
+				}
+				open = open[:i]
+				break
+			}
+		}
+	}
+}
+
+func safeHref(v string) bool {
+	v = strings.ToLower(strings.TrimSpace(v))
+	return strings.HasPrefix(v, "http://") || strings.HasPrefix(v, "https://") || strings.HasPrefix(v, "mailto:")
+}
+
+// HTMLToText strips markup, keeping line breaks at block boundaries.
+func HTMLToText(in string) string {
+	var b strings.Builder
+	z := html.NewTokenizer(strings.NewReader(in))
+	skipDepth := 0
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			return strings.TrimSpace(b.String())
+		case html.TextToken:
+			if skipDepth == 0 {
+				b.Write(z.Text())
+			}
+		case html.StartTagToken, html.SelfClosingTagToken, html.EndTagToken:
+			name, _ := z.TagName()
+			tag := string(name)
+			if droppedTags[tag] {
+				if tt == html.StartTagToken {
+					skipDepth++
+				} else if tt == html.EndTagToken && skipDepth > 0 {
+					skipDepth--
+				}
+				continue
+			}
+			switch tag {
+			case "br", "p", "div", "li", "tr", "h1", "h2", "h3", "h4", "h5", "h6":
+				b.WriteString("\n")
+			}
+		}
+	}
+}
+
+// ---------- Cache ----------
+
+// ThreadCache keeps fetched threads for a short time so reopening a job
+// doesn't hit Gmail again.
+type ThreadCache struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]threadCacheEntry
+}
+
+type threadCacheEntry struct {
+	thread  *EmailThread
+	expires time.Time
+}
+
+func NewThreadCache(ttl time.Duration) *ThreadCache {
+	return &ThreadCache{ttl: ttl, entries: make(map[string]threadCacheEntry)}
+}
+
+func threadCacheKey(userID int, threadID string) string {
+	return fmt.Sprintf("%d:%s", userID, threadID)
+}
+
+func (c *ThreadCache) Get(userID int, threadID string) (*EmailThread, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[threadCacheKey(userID, threadID)]
+	if !ok || time.Now().After(e.expires) {
+		return nil, false
+	}
+	return e.thread, true
+}
+
+func (c *ThreadCache) Set(userID int, threadID string, t *EmailThread) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for k, e := range c.entries {
+		if now.After(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[threadCacheKey(userID, threadID)] = threadCacheEntry{thread: t, expires: now.Add(c.ttl)}
+}
+
+// Forget drops every cached thread for a user (e.g. on disconnect).
+func (c *ThreadCache) Forget(userID int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	prefix := fmt.Sprintf("%d:", userID)
+	for k := range c.entries {
+		if strings.HasPrefix(k, prefix) {
+			delete(c.entries, k)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package services
+
+import "testing"
+
+func TestSanitizeHTML(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"formatting kept", `<p>Hi <b>Ana</b>,<br>thanks</p>`, `<p>Hi <b>Ana</b>,<br>thanks</p>`},
+		{"script dropped", `<p>a</p><script>alert(1)</script><p>b</p>`, `<p>a</p><p>b</p>`},
+		{"unclosed script drops the rest", `a<script>alert(1)`, `a`},
+		{"style dropped", `<style>p{color:red}</style><p>x</p>`, `<p>x</p>`},
+		{"head and title dropped", `<html><head><title>T</title></head><body><p>x</p></body></html>`, `<p>x</p>`},
+		{"event handlers dropped", `<div onclick="steal()" onmouseover="x()">hi</div>`, `<div>hi</div>`},
+		{"style and class attributes dropped", `<span style="color:red" class="x">hi</span>`, `<span>hi</span>`},
+		{"image dropped", `<p><img src="https://t.example/p.gif" onerror="x()">x</p>`, `<p>x</p>`},
+		{"iframe dropped", `<iframe src="https://evil.example"></iframe>ok`, `ok`},
+		{"https link", `<a href="https://example.com/job?a=1&amp;b=2">job</a>`,
+			`<a href="https://example.com/job?a=1&amp;b=2" rel="noopener noreferrer" target="_blank">job</a>`},
+		{"mailto link", `<a href="MAILTO:hr@example.com">mail</a>`,
+			`<a href="MAILTO:hr@example.com" rel="noopener noreferrer" target="_blank">mail</a>`},
+		{"javascript href", `<a href="javascript:alert(1)">x</a>`, `<a>x</a>`},
+		{"mixed case javascript", `<a href=" JaVaScRiPt:alert(1)">x</a>`, `<a>x</a>`},
+		{"entity encoded javascript", `<a href="&#106;avascript:alert(1)">x</a>`, `<a>x</a>`},
+		{"hex entity encoded javascript", `<a href="&#x6A;&#x61;vascript:alert(1)">x</a>`, `<a>x</a>`},
+		{"javascript with a tab", "<a href=\"java\tscript:alert(1)\">x</a>", `<a>x</a>`},
+		{"data href", `<a href="data:text/html;base64,PHNjcmlwdD4=">x</a>`, `<a>x</a>`},
+		{"mixed case data href", `<a href="DaTa:text/html,<script>x</script>">x</a>`, `<a>x</a>`},
+		{"a without href", `<a name="top">top</a>`, `<a>top</a>`},
+		{"unclosed tags closed", `<div><b>bold <i>both`, `<div><b>bold <i>both</i></b></div>`},
+		{"misnested tags", `<b><i>x</b>y</i>`, `<b><i>x</i></b>y`},
+		{"stray end tags dropped", `</div></p>text</table>`, `text`},
+		{"self-closing void tags", `a<br/>b<hr/>c`, `a<br>b<hr>c`},
+		{"text escaped", `1 < 2 & "3" > 0`, `1 &lt; 2 &amp; &#34;3&#34; &gt; 0`},
+		{"escaped markup stays text", `&lt;script&gt;alert(1)&lt;/script&gt;`, `&lt;script&gt;alert(1)&lt;/script&gt;`},
+		{"attribute breakout", `<a href="https://x.example/&quot;onmouseover=&quot;alert(1)">x</a>`,
+			`<a href="https://x.example/&#34;onmouseover=&#34;alert(1)" rel="noopener noreferrer" target="_blank">x</a>`},
+	}
+	for _, tt := range tests {
+		if got := SanitizeHTML(tt.in); got != tt.want {
+			t.Errorf("%s: SanitizeHTML(%q)\n got %q\nwant %q", tt.name, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestHTMLToText(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"paragraphs", `<p>Hello</p><p>World</p>`, "Hello\n\nWorld"},
+		{"line breaks", `a<br>b<br/>c`, "a\nb\nc"},
+		{"script and style dropped", `<style>p{}</style>Hi<script>alert(1)</script> there`, "Hi there"},
+		{"entities decoded", `Tom &amp; Jerry &lt;3`, "Tom & Jerry <3"},
+		{"list items", `<ul><li>one</li><li>two</li></ul>`, "one\n\ntwo"},
+		{"unclosed tags", `<div><b>bold`, "bold"},
+	}
+	for _, tt := range tests {
+		if got := HTMLToText(tt.in); got != tt.want {
+			t.Errorf("%s: HTMLToText(%q) = %q, want %q", tt.name, tt.in, got, tt.want)
+		}
+	}
+}
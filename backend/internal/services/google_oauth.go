@@ -2,16 +2,23 @@ package services
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"net/url"
 	"os"
 	"strings"
 
+	"github.com/gant123/jobTracker/internal/repository"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 )
 
 type GoogleOAuth struct {
 	Config *oauth2.Config
+	// Quota, when set, meters and paces every Gmail call made through
+	// UserClient.
+	Quota *GmailQuota
 }
 
 func NewGoogleOAuth() *GoogleOAuth {
@@ -40,3 +47,46 @@ func (g *GoogleOAuth) Exchange(ctx context.Context, code string) (*oauth2.Token,
 func (g *GoogleOAuth) Client(ctx context.Context, token *oauth2.Token) *http.Client {
 	return g.Config.Client(ctx, token)
 }
+
+// UserClient returns an *http.Client for userID whose refreshed tokens are
+// persisted back to repo, and whose invalid_grant failures flag the stored
+// token as needing re-authorization.
+func (g *GoogleOAuth) UserClient(ctx context.Context, repo repository.TokenRepository, userID int, provider string, token *oauth2.Token) *http.Client {
+	ts := NewPersistingTokenSource(ctx, g.Config.TokenSource(ctx, token), repo, userID, provider, token)
+	client := oauth2.NewClient(ctx, oauth2.ReuseTokenSource(token, ts))
+	if g.Quota != nil {
+		client.Transport = &quotaTransport{base: client.Transport, quota: g.Quota, userID: userID}
+	}
+	return client
+}
+
+const revokeURL = "https://oauth2.googleapis.com/revoke"
+
+// Revoke invalidates the grant behind token (refresh or access token) at
+// Google. A token Google no longer recognises counts as revoked.
+func (g *GoogleOAuth) Revoke(ctx context.Context, token string) error {
+	form := url.Values{"token": {token}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, revokeURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("revoke request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	var body struct {
+		Error string `json:"error"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&body)
+	if body.Error == "invalid_token" {
+		return nil
+	}
+	return fmt.Errorf("revoke failed: %s %s", resp.Status, body.Error)
+}
@@ -8,12 +8,22 @@ import (
 )
 
 type JobService struct {
-	jobRepo *repository.JobRepository
+	jobRepo      *repository.JobRepository
+	jobEmailRepo *repository.JobEmailRepository
+	contactRepo  *repository.JobContactRepository
+	classifier   *ClassifierService
+	filters      *repository.ScanFilterRepository
+	postings     *PostingService
 }
 
-func NewJobService(jobRepo *repository.JobRepository) *JobService {
+func NewJobService(jobRepo *repository.JobRepository, jobEmailRepo *repository.JobEmailRepository, contactRepo *repository.JobContactRepository, classifier *ClassifierService, filters *repository.ScanFilterRepository, postings *PostingService) *JobService {
 	return &JobService{
-		jobRepo: jobRepo,
+		jobRepo:      jobRepo,
+		jobEmailRepo: jobEmailRepo,
+		contactRepo:  contactRepo,
+		classifier:   classifier,
+		filters:      filters,
+		postings:     postings,
 	}
 }
 
@@ -27,6 +37,7 @@ func (s *JobService) CreateJob(userID int, req *models.CreateJobRequest) (*model
 		SalaryMin:      req.SalaryMin,
 		SalaryMax:      req.SalaryMax,
 		Currency:       req.Currency,
+		SalaryPeriod:   req.SalaryPeriod,
 		Status:         req.Status,
 		URL:            req.URL,
 		Description:    req.Description,
@@ -34,11 +45,18 @@ func (s *JobService) CreateJob(userID int, req *models.CreateJobRequest) (*model
 		AppliedDate:    req.AppliedDate,
 		InterviewDate:  req.InterviewDate,
 		GmailMessageID: req.GmailMessageID,
+		SourceKey:      req.SourceKey,
 	}
 
 	if job.Status == "" {
 		job.Status = "applied"
 	}
+	if !SalaryPeriods[job.SalaryPeriod] {
+		job.SalaryPeriod = ""
+	}
+	if job.SalaryMin == nil && job.SalaryMax == nil && req.Salary != "" {
+		applySalary(job, req.Salary)
+	}
 
 	if job.Currency == "" {
 		job.Currency = "USD"
@@ -53,6 +71,50 @@ func (s *JobService) CreateJob(userID int, req *models.CreateJobRequest) (*model
 		// For any other error, report it as a failure.
 		return nil, fmt.Errorf("failed to create job: %w", err)
 	}
+
+	if src := req.SourceEmail; src != nil && src.GmailMessageID != "" {
+		email := &models.JobEmail{
+			JobID:          job.ID,
+			UserID:         userID,
+			GmailMessageID: src.GmailMessageID,
+			ThreadID:       src.ThreadID,
+			Subject:        src.Subject,
+			From:           src.From,
+			Snippet:        src.Snippet,
+			ReceivedAt:     src.ReceivedAt,
+			Direction:      src.Direction,
+			Classification: src.Classification,
+			Link:           src.Link,
+		}
+		if err := s.jobEmailRepo.Create(email); err != nil && !errors.Is(err, repository.ErrDuplicate) {
+			return nil, fmt.Errorf("failed to link source email: %w", err)
+		}
+	}
+
+	source := "manual"
+	if req.GmailMessageID != "" {
+		source = "gmail"
+	}
+	for _, c := range req.Contacts {
+		if c.Email == "" {
+			continue
+		}
+		contact := &models.JobContact{
+			JobID:  job.ID,
+			UserID: userID,
+			Name:   c.Name,
+			Email:  c.Email,
+			Role:   c.Role,
+			Source: source,
+		}
+		if err := s.contactRepo.Create(contact); err != nil && !errors.Is(err, repository.ErrDuplicate) {
+			return nil, fmt.Errorf("failed to save contact: %w", err)
+		}
+	}
+
+	// The posting may state the range the user didn't; the job exists
+	// either way, so this is best effort.
+	_ = s.postings.Queue(job)
 	return job, nil
 }
 
@@ -64,12 +126,29 @@ func (s *JobService) GetJobs(userID int, filter *models.JobFilter) ([]*models.Jo
 	return s.jobRepo.GetAllByUserID(userID, filter)
 }
 
+// GetJobEmails returns the correspondence timeline of a job owned by userID.
+func (s *JobService) GetJobEmails(id int, userID int) ([]*models.JobEmail, error) {
+	if _, err := s.jobRepo.GetByID(id, userID); err != nil {
+		return nil, err
+	}
+	return s.jobEmailRepo.GetByJobID(id, userID)
+}
+
+// GetJobContacts returns the contacts of a job owned by userID.
+func (s *JobService) GetJobContacts(id int, userID int) ([]*models.JobContact, error) {
+	if _, err := s.jobRepo.GetByID(id, userID); err != nil {
+		return nil, err
+	}
+	return s.contactRepo.GetByJobID(id, userID)
+}
+
 func (s *JobService) UpdateJob(id int, userID int, req *models.UpdateJobRequest) (*models.Job, error) {
 	// Get existing job
 	job, err := s.jobRepo.GetByID(id, userID)
 	if err != nil {
 		return nil, err
 	}
+	before := *job
 
 	// Update fields
 	if req.Company != "" {
@@ -93,6 +172,12 @@ func (s *JobService) UpdateJob(id int, userID int, req *models.UpdateJobRequest)
 	if req.Currency != "" {
 		job.Currency = req.Currency
 	}
+	if SalaryPeriods[req.SalaryPeriod] {
+		job.SalaryPeriod = req.SalaryPeriod
+	}
+	if req.SalaryMin == nil && req.SalaryMax == nil && req.Salary != "" {
+		applySalary(job, req.Salary)
+	}
 	if req.Status != "" {
 		job.Status = req.Status
 	}
@@ -116,13 +201,114 @@ func (s *JobService) UpdateJob(id int, userID int, req *models.UpdateJobRequest)
 		return nil, fmt.Errorf("failed to update job: %w", err)
 	}
 
+	// Edits to Gmail imports are training data for the user's classifier,
+	// and fields the user set by hand are off limits to re-classification.
+	// The update itself already succeeded, so this is best effort.
+	if job.GmailMessageID != "" {
+		_ = s.classifier.RecordCorrections(&before, job, s.sourceEmail(job))
+		_ = s.jobRepo.MarkFieldsEdited(job.ID, editedFields(&before, job))
+	}
+	if job.URL != before.URL {
+		_ = s.postings.Queue(job)
+	}
+
 	return job, nil
 }
 
+// applySalary fills a job's pay range from free text like "$120k–$140k".
+func applySalary(job *models.Job, text string) {
+	sal, ok := ExtractSalary(text)
+	if !ok {
+		return
+	}
+	job.SalaryMin, job.SalaryMax = &sal.Min, &sal.Max
+	job.Currency, job.SalaryPeriod = sal.Currency, sal.Period
+}
+
+// editedFields lists the extractor-filled fields an update changed.
+func editedFields(before, after *models.Job) []string {
+	var fields []string
+	if before.Company != after.Company {
+		fields = append(fields, "company")
+	}
+	if before.Position != after.Position {
+		fields = append(fields, "position")
+	}
+	if before.Location != after.Location {
+		fields = append(fields, "location")
+	}
+	if before.Status != after.Status {
+		fields = append(fields, "status")
+	}
+	return fields
+}
+
+// sourceEmail returns the email a Gmail-imported job was created from.
+func (s *JobService) sourceEmail(job *models.Job) *models.JobEmail {
+	emails, err := s.jobEmailRepo.GetByJobID(job.ID, job.UserID)
+	if err != nil {
+		return nil
+	}
+	for _, e := range emails {
+		if e.GmailMessageID == job.GmailMessageID {
+			return e
+		}
+	}
+	return nil
+}
+
 func (s *JobService) DeleteJob(id int, userID int) error {
 	return s.jobRepo.Delete(id, userID)
 }
 
+// NotAJob handles the "not a job email" action on an imported job: the job
+// is deleted, its message is remembered so scans skip it, and the sender
+// or their whole domain is blocked if asked.
+func (s *JobService) NotAJob(id int, userID int, req *models.NotAJobRequest) (*models.NotAJobResult, error) {
+	job, err := s.jobRepo.GetByID(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if job.GmailMessageID == "" {
+		return nil, fmt.Errorf("job was not imported from gmail")
+	}
+
+	result := &models.NotAJobResult{GmailMessageID: job.GmailMessageID}
+	if src := s.sourceEmail(job); src != nil {
+		result.Sender = senderAddress(src.From)
+		result.Domain = senderDomain(src.From)
+	}
+
+	var pattern string
+	switch req.Block {
+	case "":
+	case "sender":
+		pattern = result.Sender
+	case "domain":
+		pattern = result.Domain
+	default:
+		return nil, fmt.Errorf("block must be sender or domain")
+	}
+	if req.Block != "" && pattern == "" {
+		return nil, fmt.Errorf("sender of this job is unknown")
+	}
+
+	if err := s.filters.DismissMessage(userID, job.GmailMessageID, "not_a_job"); err != nil {
+		return nil, err
+	}
+	if pattern != "" {
+		rule := &models.SenderRule{UserID: userID, Pattern: pattern, Kind: "block"}
+		if err := s.filters.SaveRule(rule); err != nil {
+			return nil, err
+		}
+		result.Blocked = rule
+	}
+	if err := s.jobRepo.Delete(id, userID); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
 func (s *JobService) GetStats(userID int) (map[string]int, error) {
 	return s.jobRepo.GetStats(userID)
 }
@@ -0,0 +1,268 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gant123/jobTracker/internal/jobs"
+	"github.com/gant123/jobTracker/internal/models"
+	"github.com/gant123/jobTracker/internal/repository"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/html"
+)
+
+// ---------- Posting pages ----------
+
+// PostingSalaryJob reads the pay range off a job's posting page. Pages
+// that turn us away once usually keep doing so.
+var PostingSalaryJob = jobs.Type[PostingSalaryPayload]{
+	Name:  "posting_salary",
+	Retry: &repository.RetryPolicy{MaxAttempts: 2, BaseDelay: 10 * time.Minute, MaxDelay: time.Hour},
+}
+
+type PostingSalaryPayload struct {
+	JobID int `json:"job_id"`
+}
+
+// Posting pages are fetched on behalf of users; anything bigger than this
+// is not a job ad.
+const maxPostingBytes = 2 << 20
+
+var errPrivateAddress = errors.New("posting url points at a private address")
+
+type PostingService struct {
+	client   *http.Client
+	jobRepo  *repository.JobRepository
+	jobQueue *repository.JobQueueRepository
+}
+
+func NewPostingService(jobRepo *repository.JobRepository, jobQueue *repository.JobQueueRepository) *PostingService {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		// The url comes from the user; don't let it reach the database or
+		// anything else on our network.
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+				ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() {
+				return errPrivateAddress
+			}
+			return nil
+		},
+	}
+	transport := &http.Transport{
+		DialContext:         dialer.DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+	}
+	return &PostingService{
+		client:   &http.Client{Transport: transport, Timeout: 20 * time.Second},
+		jobRepo:  jobRepo,
+		jobQueue: jobQueue,
+	}
+}
+
+// Queue asks the worker to look for a salary on the job's posting, if it
+// links one and has no range yet.
+func (s *PostingService) Queue(job *models.Job) error {
+	if job.ID == 0 || job.SalaryMin != nil || job.SalaryMax != nil || !followablePosting(job.URL) {
+		return nil
+	}
+	_, err := PostingSalaryJob.EnqueueUnique(s.jobQueue, job.UserID, strconv.Itoa(job.ID), PostingSalaryPayload{JobID: job.ID})
+	return err
+}
+
+// RunJob is the PostingSalaryJob handler.
+func (s *PostingService) RunJob(ctx context.Context, log *logrus.Entry, job *jobs.Job, p PostingSalaryPayload) error {
+	return s.Run(ctx, job.UserID, p.JobID)
+}
+
+// Run fetches the job's posting and fills in its pay range. A posting that
+// doesn't state one is not an error.
+func (s *PostingService) Run(ctx context.Context, userID, jobID int) error {
+	job, err := s.jobRepo.GetByID(jobID, userID)
+	if err != nil {
+		return err
+	}
+	if job.SalaryMin != nil || job.SalaryMax != nil || !followablePosting(job.URL) {
+		return nil
+	}
+
+	page, err := s.fetch(ctx, job.URL)
+	if err != nil {
+		return err
+	}
+	sal, ok := PostingSalary(page)
+	if !ok {
+		return nil
+	}
+	_, err = s.jobRepo.FillSalary(job.ID, userID, sal.Min, sal.Max, sal.Currency, sal.Period)
+	return err
+}
+
+func (s *PostingService) fetch(ctx context.Context, rawURL string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; JobTracker/1.0)")
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch posting: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to fetch posting: %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxPostingBytes))
+	if err != nil {
+		return "", fmt.Errorf("failed to read posting: %w", err)
+	}
+	return string(body), nil
+}
+
+// followablePosting skips links that aren't web pages, and Gmail links,
+// which only open for the signed-in user.
+func followablePosting(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return false
+	}
+	return !strings.HasSuffix(u.Hostname(), "mail.google.com")
+}
+
+// PostingSalary reads the pay range off a posting page. Job boards publish
+// schema.org JobPosting data for search engines, which is exact; the
+// visible text is the fallback.
+func PostingSalary(page string) (Salary, bool) {
+	for _, block := range jsonLDBlocks(page) {
+		var v any
+		if json.Unmarshal([]byte(block), &v) != nil {
+			continue
+		}
+		if s, ok := salaryFromJSONLD(v); ok {
+			return s, true
+		}
+	}
+	return ExtractSalary(HTMLToText(page))
+}
+
+func jsonLDBlocks(page string) []string {
+	var out []string
+	z := html.NewTokenizer(strings.NewReader(page))
+	inBlock := false
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return out
+		case html.StartTagToken:
+			tok := z.Token()
+			if tok.Data != "script" {
+				continue
+			}
+			for _, a := range tok.Attr {
+				if a.Key == "type" && strings.Contains(strings.ToLower(a.Val), "ld+json") {
+					inBlock = true
+				}
+			}
+		case html.TextToken:
+			if inBlock {
+				out = append(out, string(z.Text()))
+			}
+		case html.EndTagToken:
+			inBlock = false
+		}
+	}
+}
+
+// salaryFromJSONLD looks for a JobPosting's baseSalary, which may sit at
+// the top level, in a list or inside an @graph.
+func salaryFromJSONLD(v any) (Salary, bool) {
+	switch t := v.(type) {
+	case []any:
+		for _, e := range t {
+			if s, ok := salaryFromJSONLD(e); ok {
+				return s, true
+			}
+		}
+	case map[string]any:
+		if b, ok := t["baseSalary"]; ok {
+			if s, ok := baseSalary(b); ok {
+				return s, true
+			}
+		}
+		return salaryFromJSONLD(t["@graph"])
+	}
+	return Salary{}, false
+}
+
+// baseSalary reads a schema.org MonetaryAmount:
+//
+//	{"currency": "USD", "value": {"minValue": 120000, "maxValue": 150000, "unitText": "YEAR"}}
+func baseSalary(v any) (Salary, bool) {
+	m, ok := v.(map[string]any)
+	if !ok {
+		if text, ok := v.(string); ok {
+			return ExtractSalary(text)
+		}
+		return Salary{}, false
+	}
+	cur, _ := m["currency"].(string)
+	cur = strings.ToUpper(strings.TrimSpace(cur))
+	if len(cur) != 3 {
+		return Salary{}, false
+	}
+
+	var low, high float64
+	unit := ""
+	if q, ok := m["value"].(map[string]any); ok {
+		low, high = jsonAmount(q["minValue"]), jsonAmount(q["maxValue"])
+		if v := jsonAmount(q["value"]); v > 0 && low == 0 && high == 0 {
+			low, high = v, v
+		}
+		unit, _ = q["unitText"].(string)
+	} else {
+		low = jsonAmount(m["value"])
+		high = low
+	}
+	if low == 0 {
+		low = high
+	}
+	if high == 0 {
+		high = low
+	}
+	if low > high {
+		low, high = high, low
+	}
+
+	period := payPeriodWords[strings.ToLower(unit)]
+	if period == "" {
+		period = guessPayPeriod(high)
+	}
+	return newSalary(low, high, cur, period)
+}
+
+func jsonAmount(v any) float64 {
+	switch n := v.(type) {
+	case float64:
+		return n
+	case string:
+		f, _ := parseAmount(n)
+		return f
+	}
+	return 0
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gant123/jobTracker/internal/jobs"
+	"github.com/gant123/jobTracker/internal/models"
+	"github.com/gant123/jobTracker/internal/repository"
+	"google.golang.org/api/googleapi"
+)
+
+// ---------- Re-classification ----------
+
+// ReclassifyJob re-runs the current extractors over a user's past Gmail
+// imports and stores the differences as proposals. GmailJobs runs it.
+var ReclassifyJob = jobs.Type[jobs.None]{Name: "gmail_reclassify"}
+
+// ReclassifyResult summarises one re-classification run.
+type ReclassifyResult struct {
+	Checked  int `json:"checked"`
+	Proposed int `json:"proposed"`
+	Missing  int `json:"missing"` // messages no longer in the mailbox
+	Failed   int `json:"failed"`
+}
+
+type ReclassifyService struct {
+	repo     *repository.ReclassifyRepository
+	jobQueue *repository.JobQueueRepository
+	profiles *ScanProfiles
+}
+
+func NewReclassifyService(repo *repository.ReclassifyRepository, jobQueue *repository.JobQueueRepository, profiles *ScanProfiles) *ReclassifyService {
+	return &ReclassifyService{repo: repo, jobQueue: jobQueue, profiles: profiles}
+}
+
+// Queue enqueues a run unless one is already waiting or running. The user
+// asked for it, so it goes ahead of background work.
+func (s *ReclassifyService) Queue(userID int) error {
+	_, err := ReclassifyJob.WithPriority(repository.PriorityInteractive).EnqueueUnique(s.jobQueue, userID, "", jobs.None{})
+	return err
+}
+
+func (s *ReclassifyService) Pending(userID int) (bool, error) {
+	return s.jobQueue.HasPendingJob(ReclassifyJob.Name, userID)
+}
+
+// Run fetches the metadata of every one-job Gmail import again, re-runs
+// the extractors and replaces the user's proposals with the new diff.
+func (s *ReclassifyService) Run(ctx context.Context, fetcher MessageFetcher, userID int) (*ReclassifyResult, error) {
+	candidates, err := s.repo.ListCandidates(userID)
+	if err != nil {
+		return nil, err
+	}
+	profile, err := s.profiles.Load(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(candidates))
+	for _, c := range candidates {
+		ids = append(ids, c.GmailMessageID)
+	}
+	fetched, err := fetcher.Metadata(ctx, ids, []string{"Subject", "Date", "From", "To"})
+	if err != nil {
+		return nil, err
+	}
+
+	res := &ReclassifyResult{Checked: len(candidates)}
+	var proposals []*models.ReclassifyProposal
+	for _, c := range candidates {
+		msg := fetched.Messages[c.GmailMessageID]
+		if msg == nil {
+			var gerr *googleapi.Error
+			if errors.As(fetched.Errors[c.GmailMessageID], &gerr) && gerr.Code == http.StatusNotFound {
+				res.Missing++
+			} else {
+				res.Failed++
+			}
+			continue
+		}
+
+		meta := parseMessageMeta(msg)
+		var ev EmailJobEvent
+		if c.Direction == "outbound" {
+			var ok bool
+			if ev, ok = classifySent(meta); !ok {
+				continue
+			}
+		} else {
+			ev, _ = classifyMessage(meta, profile)
+		}
+		proposals = append(proposals, ProposeChanges(c, ev)...)
+	}
+	if err := s.repo.ReplaceProposals(userID, proposals); err != nil {
+		return nil, err
+	}
+	res.Proposed = len(proposals)
+	return res, nil
+}
+
+// ProposeChanges diffs a stored import against what the extractors say
+// now. Fields the user edited by hand are left alone, as are extractor
+// results that came back empty.
+func ProposeChanges(c *models.ReclassifyCandidate, ev EmailJobEvent) []*models.ReclassifyProposal {
+	var out []*models.ReclassifyProposal
+	propose := func(field, oldValue, newValue string) {
+		if c.Edited[field] || newValue == "" || newValue == oldValue {
+			return
+		}
+		out = append(out, &models.ReclassifyProposal{
+			JobID:    c.JobID,
+			Field:    field,
+			OldValue: oldValue,
+			NewValue: newValue,
+		})
+	}
+	propose("company", c.Company, ev.Company)
+	propose("position", c.Position, ev.Title)
+	propose("location", c.Location, ev.Location)
+	// Only second-guess statuses the scanner assigned; anything else is
+	// the application having moved on.
+	if ClassifiedStatuses[c.Status] {
+		propose("status", c.Status, ev.Status)
+	}
+	return out
+}
+
+func (s *ReclassifyService) Proposals(userID int) ([]*models.ReclassifyProposal, error) {
+	return s.repo.ListProposals(userID)
+}
+
+// Apply writes the chosen proposals, or all of them, into their jobs.
+func (s *ReclassifyService) Apply(userID int, req *models.ApplyProposalsRequest) (int, error) {
+	if req.All {
+		return s.repo.ApplyProposals(userID, nil)
+	}
+	if len(req.IDs) == 0 {
+		return 0, nil
+	}
+	return s.repo.ApplyProposals(userID, req.IDs)
+}
+
+func (s *ReclassifyService) Discard(userID int) error {
+	return s.repo.DeleteProposals(userID)
+}
@@ -0,0 +1,224 @@
+package services
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ---------- Salary ranges ----------
+
+// Salary is a pay range read out of free text. Min and Max are whole units
+// of Currency per Period; a single figure ("$55/hr") sets both.
+type Salary struct {
+	Min      int    `json:"min"`
+	Max      int    `json:"max"`
+	Currency string `json:"currency"` // ISO 4217
+	Period   string `json:"period"`   // hour|day|week|month|year
+}
+
+// SalaryPeriods lists the pay periods a Salary can have.
+var SalaryPeriods = map[string]bool{
+	"hour": true, "day": true, "week": true, "month": true, "year": true,
+}
+
+// An amount with its currency on either side: "$120k", "€60.000",
+// "60 000 EUR", "CA$95,000". Groups: prefix, number, multiplier, suffix.
+const moneyPattern = `(US\$|CA?\$|AU?\$|[$€£]|\b(?:USD|EUR|GBP|CAD|AUD|CHF)\b)?\s?` +
+	`(\d{1,3}(?:[.,\x{00a0}\x{202f} ]\d{3})+(?:[.,]\d{1,2})?|\d+(?:[.,]\d{1,2})?)` +
+	`(?:\s?([km])\b)?` +
+	`(?:\s?([€£]|\b(?:USD|EUR|GBP|CAD|AUD|CHF)\b))?`
+
+var (
+	// A range may repeat the period after its first figure, as in
+	// "$120K/yr - $150K/yr".
+	reSalary = regexp.MustCompile(`(?i)` + moneyPattern + `(?:\s*/\s*[a-z]{1,5}\b)?` +
+		`(?:\s*(?:-|–|—|to|bis|à|a|tot)\s*` + moneyPattern + `)?`)
+	// What follows the amount: "/ year", "per hour", "an hour", "p.a.",
+	// "pro Jahr", "par an"...
+	rePayPeriod = regexp.MustCompile(`(?i)^\s*(?:(?:/|per|pro|par|por|a|an|each)\s*)?` +
+		`(hour|hr|h|day|week|wk|month|mo|year|yr|annum|` +
+		`stunde|std|tag|woche|monat|jahr|heure|jour|semaine|mois|an|année|` +
+		`hora|día|dia|semana|mes|año|ano|uur|dag|maand|jaar)\b|` +
+		`^\s*(hourly|daily|weekly|monthly|annually|yearly|p\.\s?a\.|pa\b)`)
+)
+
+var salaryCurrencies = map[string]string{
+	"$": "USD", "us$": "USD", "c$": "CAD", "ca$": "CAD", "a$": "AUD", "au$": "AUD",
+	"€": "EUR", "£": "GBP",
+	"usd": "USD", "eur": "EUR", "gbp": "GBP", "cad": "CAD", "aud": "AUD", "chf": "CHF",
+}
+
+var payPeriodWords = map[string]string{
+	"hour": "hour", "hr": "hour", "h": "hour", "hourly": "hour",
+	"stunde": "hour", "std": "hour", "heure": "hour", "hora": "hour", "uur": "hour",
+	"day": "day", "daily": "day", "tag": "day", "jour": "day", "día": "day", "dia": "day", "dag": "day",
+	"week": "week", "wk": "week", "weekly": "week", "woche": "week", "semaine": "week", "semana": "week",
+	"month": "month", "mo": "month", "monthly": "month", "monat": "month", "mois": "month",
+	"mes": "month", "maand": "month",
+	"year": "year", "yr": "year", "annum": "year", "annually": "year", "yearly": "year",
+	"jahr": "year", "an": "year", "année": "year", "año": "year", "ano": "year", "jaar": "year",
+	"p.a.": "year", "p. a.": "year", "pa": "year",
+}
+
+// Plausible amounts per period; anything outside is a bonus, a price or a
+// phone number, not pay.
+var payPeriodBounds = map[string][2]float64{
+	"hour":  {5, 2000},
+	"day":   {40, 20000},
+	"week":  {150, 50000},
+	"month": {500, 500000},
+	"year":  {5000, 10000000},
+}
+
+// ExtractSalary finds the first pay range in text and normalizes it.
+// Amounts must carry a currency; the period is read from what follows
+// ("/hr", "per year") or, when missing, guessed from the size of the
+// figures.
+func ExtractSalary(text string) (Salary, bool) {
+	for _, m := range reSalary.FindAllStringSubmatchIndex(text, -1) {
+		g := func(i int) string {
+			if m[2*i] < 0 {
+				return ""
+			}
+			return text[m[2*i]:m[2*i+1]]
+		}
+		// The period follows the whole match, or the first figure when the
+		// range repeats it.
+		first := max(m[5], m[7], m[9])
+		if s, ok := salaryFromMatch(g, text[m[1]:], text[first:]); ok {
+			return s, true
+		}
+	}
+	return Salary{}, false
+}
+
+// salaryFromMatch reads one reSalary match: groups 1-4 are the first
+// amount, 5-8 the optional second one.
+func salaryFromMatch(g func(int) string, rest ...string) (Salary, bool) {
+	cur := ""
+	for _, i := range []int{1, 4, 5, 8} {
+		if c := salaryCurrencies[strings.ToLower(g(i))]; c != "" {
+			cur = c
+			break
+		}
+	}
+	if cur == "" {
+		return Salary{}, false
+	}
+
+	low, ok := parseAmount(g(2))
+	if !ok {
+		return Salary{}, false
+	}
+	high := low
+	lowMult, highMult := g(3), g(3)
+	if g(6) != "" {
+		if high, ok = parseAmount(g(6)); !ok {
+			return Salary{}, false
+		}
+		highMult = g(7)
+		// "$120–150k" puts the multiplier on the last figure only.
+		if lowMult == "" && highMult != "" && low < 1000 {
+			lowMult = highMult
+		}
+	}
+	low *= multiplier(lowMult)
+	high *= multiplier(highMult)
+	if low > high {
+		low, high = high, low
+	}
+
+	period := ""
+	for _, r := range rest {
+		if p := rePayPeriod.FindStringSubmatch(r); p != nil {
+			period = payPeriodWords[strings.ToLower(p[1]+p[2])]
+			break
+		}
+	}
+	if period == "" {
+		// A lone small figure is more likely a price than an hourly rate.
+		if g(6) == "" && high < 1000 {
+			return Salary{}, false
+		}
+		period = guessPayPeriod(high)
+	}
+	return newSalary(low, high, cur, period)
+}
+
+// newSalary checks a range is plausible pay for its period.
+func newSalary(low, high float64, currency, period string) (Salary, bool) {
+	bounds, ok := payPeriodBounds[period]
+	if !ok || low < bounds[0] || high > bounds[1] {
+		return Salary{}, false
+	}
+	return Salary{
+		// The jobs table keeps whole units; cents don't matter for a range.
+		Min:      int(math.Round(low)),
+		Max:      int(math.Round(high)),
+		Currency: currency,
+		Period:   period,
+	}, true
+}
+
+// parseAmount reads "60.000", "60,000", "1.234,50", "55.5" and "60 000".
+// A trailing separator followed by one or two digits is a decimal point;
+// every other separator groups thousands.
+func parseAmount(s string) (float64, bool) {
+	s = strings.NewReplacer(" ", "", "\u00a0", "", "\u202f", "").Replace(s)
+	frac := ""
+	if k := strings.LastIndexAny(s, ".,"); k != -1 && len(s)-k-1 <= 2 {
+		s, frac = s[:k], s[k+1:]
+	}
+	s = strings.NewReplacer(".", "", ",", "").Replace(s)
+	if frac != "" {
+		s += "." + frac
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	return v, err == nil && v > 0
+}
+
+func multiplier(m string) float64 {
+	switch strings.ToLower(m) {
+	case "k":
+		return 1e3
+	case "m":
+		return 1e6
+	}
+	return 1
+}
+
+// guessPayPeriod handles ranges that don't say what they're per.
+func guessPayPeriod(amount float64) string {
+	switch {
+	case amount >= 15000:
+		return "year"
+	case amount >= 1000:
+		return "month"
+	case amount <= 300:
+		return "hour"
+	}
+	// $300-$1000 could be a day rate, a week or a signing bonus.
+	return ""
+}
+
+// salaryFromLines finds the pay line among a posting's detail lines.
+// Lines that are little more than the range ("$120K/yr - $150K/yr") are
+// dropped so they aren't mistaken for a title or a "Company - Location".
+// The search runs from the end, where the posting's own lines are.
+func salaryFromLines(lines []string) (Salary, []string, bool) {
+	for k := len(lines) - 1; k >= 0; k-- {
+		s, ok := ExtractSalary(lines[k])
+		if !ok {
+			continue
+		}
+		words := strings.Fields(reSalary.ReplaceAllString(lines[k], " "))
+		if len(words) > 3 {
+			return s, lines, true
+		}
+		rest := append(append([]string{}, lines[:k]...), lines[k+1:]...)
+		return s, rest, true
+	}
+	return Salary{}, lines, false
+}
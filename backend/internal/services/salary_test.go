@@ -0,0 +1,84 @@
+package services
+
+import "testing"
+
+func TestExtractSalary(t *testing.T) {
+	tests := []struct {
+		text string
+		want Salary
+		ok   bool
+	}{
+		{"$120–150k", Salary{120000, 150000, "USD", "year"}, true},
+		{"$120K/yr - $150K/yr", Salary{120000, 150000, "USD", "year"}, true},
+		{"Pay: $55/hr", Salary{55, 55, "USD", "hour"}, true},
+		{"$40 - $60 per hour", Salary{40, 60, "USD", "hour"}, true},
+		{"60.000 EUR pro Jahr", Salary{60000, 60000, "EUR", "year"}, true},
+		{"€60.000 - €75.000", Salary{60000, 75000, "EUR", "year"}, true},
+		{"45 000 € par an", Salary{45000, 45000, "EUR", "year"}, true},
+		{"£3,500 per month", Salary{3500, 3500, "GBP", "month"}, true},
+		{"CA$95,000 to CA$110,000 annually", Salary{95000, 110000, "CAD", "year"}, true},
+		{"CHF 120k p.a.", Salary{120000, 120000, "CHF", "year"}, true},
+		{"USD 5,000 - 7,000 monthly", Salary{5000, 7000, "USD", "month"}, true},
+		{"$150k-$120k", Salary{120000, 150000, "USD", "year"}, true},
+		{"€30 - €45 pro Stunde", Salary{30, 45, "EUR", "hour"}, true},
+
+		// No currency, implausible or ambiguous amounts
+		{"120,000 - 150,000", Salary{}, false},
+		{"Tickets cost $25", Salary{}, false},
+		{"a $500 signing bonus", Salary{}, false},
+		{"$5 per year", Salary{}, false},
+		{"Call 555-1234", Salary{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := ExtractSalary(tt.text)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("ExtractSalary(%q) = %+v, %v; want %+v, %v", tt.text, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		in   string
+		want float64
+		ok   bool
+	}{
+		{"60.000", 60000, true},
+		{"60,000", 60000, true},
+		{"1.234,50", 1234.5, true},
+		{"1,234.50", 1234.5, true},
+		{"55.5", 55.5, true},
+		{"60 000", 60000, true},
+		{"60 000", 60000, true},
+		{"1.000.000", 1000000, true},
+		{"120", 120, true},
+		{"0", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseAmount(tt.in)
+		if ok != tt.ok || (ok && got != tt.want) {
+			t.Errorf("parseAmount(%q) = %v, %v; want %v, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestGuessPayPeriod(t *testing.T) {
+	tests := []struct {
+		amount float64
+		want   string
+	}{
+		{150000, "year"},
+		{15000, "year"},
+		{4500, "month"},
+		{1000, "month"},
+		{45, "hour"},
+		{300, "hour"},
+		{650, ""},
+	}
+	for _, tt := range tests {
+		if got := guessPayPeriod(tt.amount); got != tt.want {
+			t.Errorf("guessPayPeriod(%v) = %q, want %q", tt.amount, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/gant123/jobTracker/internal/repository"
+	"golang.org/x/oauth2"
+)
+
+// ErrGrantRevoked means Google refused to refresh the stored token
+// (invalid_grant): the user revoked access or the refresh token expired.
+// The only way forward is for the user to reconnect Gmail.
+var ErrGrantRevoked = errors.New("google grant revoked or expired")
+
+// PersistingTokenSource wraps a refreshing token source and writes every
+// rotated token back through the TokenRepository, so the next request can
+// reuse it instead of refreshing again.
+type PersistingTokenSource struct {
+	ctx      context.Context
+	base     oauth2.TokenSource
+	repo     repository.TokenRepository
+	userID   int
+	provider string
+
+	mu   sync.Mutex
+	last string // access token we last saw (and stored)
+}
+
+func NewPersistingTokenSource(ctx context.Context, base oauth2.TokenSource, repo repository.TokenRepository, userID int, provider string, current *oauth2.Token) *PersistingTokenSource {
+	s := &PersistingTokenSource{
+		ctx:      ctx,
+		base:     base,
+		repo:     repo,
+		userID:   userID,
+		provider: provider,
+	}
+	if current != nil {
+		s.last = current.AccessToken
+	}
+	return s
+}
+
+func (s *PersistingTokenSource) Token() (*oauth2.Token, error) {
+	tok, err := s.base.Token()
+	if err != nil {
+		if isInvalidGrant(err) {
+			if merr := s.repo.MarkNeedsReauth(s.ctx, s.userID, s.provider, err.Error()); merr != nil {
+				return nil, fmt.Errorf("%w: %w (also failed to flag token: %v)", ErrGrantRevoked, err, merr)
+			}
+			return nil, fmt.Errorf("%w: %w", ErrGrantRevoked, err)
+		}
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if tok.AccessToken != s.last {
+		if err := s.repo.Save(s.ctx, s.userID, s.provider, tok); err != nil {
+			// Google may have rotated the refresh token, and losing it
+			// means invalid_grant later. Fail the call instead; base keeps
+			// the token, so the next call saves it again.
+			return nil, fmt.Errorf("failed to persist refreshed %s token for user %d: %w", s.provider, s.userID, err)
+		}
+		s.last = tok.AccessToken
+	}
+	return tok, nil
+}
+
+func isInvalidGrant(err error) bool {
+	var re *oauth2.RetrieveError
+	return errors.As(err, &re) && re.ErrorCode == "invalid_grant"
+}
@@ -3,19 +3,29 @@ import React, { useMemo, useState, useCallback, useEffect } from 'react';
 import { XMarkIcon, ChevronDownIcon, ChevronRightIcon } from '@heroicons/react/24/outline';
 import toast from 'react-hot-toast';
 
-const JOB_STATUSES = ['wishlist', 'applied', 'interviewing', 'offer', 'rejected', 'withdrawn'];
+const JOB_STATUSES = ['lead', 'wishlist', 'applied', 'interviewing', 'offer', 'rejected', 'withdrawn'];
 
 const normalize = (e) => ({
   messageId: e.messageId || e.MessageID || e.message_id || '',
+  threadId: e.threadId || '',
+  from: e.from || '',
+  direction: e.direction || 'inbound',
+  contact: e.contact || null,
+  receivedAt: e.appliedDate || e.AppliedDate || e.applied_date || null,
   company: e.company || e.Company || '',
   title: e.title || e.Title || e.position || e.Position || '',
   status: e.status || e.Status || 'applied',
+  classification: e.status || e.Status || '',
   appliedDate: (e.appliedDate || e.AppliedDate || e.applied_date)
     ? new Date(e.appliedDate || e.AppliedDate || e.applied_date).toISOString().slice(0, 10)
     : new Date().toISOString().slice(0, 10),
   subject: e.subject || e.Subject || '',
   snippet: e.snippet || e.Snippet || '',
   link: e.link || e.Link || '',
+  url: e.url || '',
+  location: e.location || '',
+  salary: e.salary || null,
+  sourceKey: e.sourceKey || '',
   selected: true,
   open: false,
 });
@@ -26,6 +36,7 @@ const Row = ({ index, rows, filtered, setRow }) => {
   const idx = rows.indexOf(r);
 
   const statusColors = {
+    lead: 'bg-purple-100 text-purple-800',
     wishlist: 'bg-gray-100 text-gray-800',
     applied: 'bg-blue-100 text-blue-800',
     interviewing: 'bg-yellow-100 text-yellow-800',
@@ -186,10 +197,26 @@ const ImportModal = ({ events = [], onClose, onImport }) => {
         position: r.title.trim() || 'Unknown Position',
         status: r.status,
         applied_date: r.appliedDate ? `${r.appliedDate}T00:00:00Z` : undefined,
-        url: r.link || undefined,
-        notes: `[Imported from Gmail] ${r.subject}`,
-        location: '',
-        gmail_message_id: r.messageId
+        url: r.url || r.link || undefined,
+        location: r.location || '',
+        salary_min: r.salary?.min,
+        salary_max: r.salary?.max,
+        currency: r.salary?.currency,
+        salary_period: r.salary?.period,
+        gmail_message_id: r.messageId,
+        source_key: r.sourceKey || undefined,
+        source_email: {
+          gmail_message_id: r.messageId,
+          thread_id: r.threadId,
+          subject: r.subject,
+          from: r.from,
+          snippet: r.snippet,
+          received_at: r.receivedAt || undefined,
+          direction: r.direction,
+          classification: r.classification,
+          link: r.link
+        },
+        contacts: r.contact ? [r.contact] : undefined
       }));
     console.log('Jobs to import:', JSON.stringify(jobsToImport, null, 2));
     if (jobsToImport.length === 0) {
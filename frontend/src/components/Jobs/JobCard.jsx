@@ -12,6 +12,7 @@ import { format } from 'date-fns';
 
 const JobCard = ({ job, onEdit, onDelete, isSelecting, isSelected, onToggleSelect }) => {
   const statusColors = {
+    lead: 'bg-purple-100 text-purple-700 border-purple-300',
     wishlist: 'bg-gray-100 text-gray-700 border-gray-300',
     applied: 'bg-blue-100 text-blue-700 border-blue-300',
     interviewing: 'bg-yellow-100 text-yellow-700 border-yellow-300',
@@ -21,6 +22,7 @@ const JobCard = ({ job, onEdit, onDelete, isSelecting, isSelected, onToggleSelec
   };
 
   const statusIcons = {
+    lead: '🤝',
     wishlist: '⭐',
     applied: '📨',
     interviewing: '💬',
@@ -7,6 +7,15 @@ import toast from 'react-hot-toast';
 
 const label = (s) => s ? s.charAt(0).toUpperCase() + s.slice(1) : '';
 
+// "USD 120,000–150,000 / year"; falls back to whatever free text we have.
+const formatSalary = (job) => {
+  const { salary_min: min, salary_max: max, currency, salary_period: period } = job;
+  if (min == null && max == null) return job.salary || '';
+  const fmt = (n) => n.toLocaleString();
+  const range = min != null && max != null && min !== max ? `${fmt(min)}–${fmt(max)}` : fmt(min ?? max);
+  return `${currency || ''} ${range}${period ? ` / ${period}` : ''}`.trim();
+};
+
 const JobDetails = () => {
   const { id } = useParams();
   const navigate = useNavigate();
@@ -63,7 +72,7 @@ const JobDetails = () => {
 
         <div className="grid grid-cols-1 md:grid-cols-2 gap-4 mt-6 text-sm">
           {job.location && <p><span className="font-medium">Location:</span> {job.location}</p>}
-          {job.salary && <p><span className="font-medium">Salary:</span> {job.salary}</p>}
+          {formatSalary(job) && <p><span className="font-medium">Salary:</span> {formatSalary(job)}</p>}
           {job.appliedDate && (
             <p>
               <span className="font-medium">Applied:</span>{' '}
@@ -25,10 +25,11 @@ export const gmailService = {
     }
   },
 
-  async disconnect() {
-    console.log('[Gmail Service] Disconnecting...');
+  // imported: 'keep' | 'delete' | 'anonymize' jobs that came from Gmail
+  async disconnect(imported = 'keep') {
+    console.log('[Gmail Service] Disconnecting...', { imported });
     try {
-      const response = await api.post('/google/disconnect');
+      const response = await api.post('/google/disconnect', null, { params: { imported } });
       console.log('[Gmail Service] Disconnect response:', response.data);
       return response.data;
     } catch (error) {
@@ -37,8 +38,9 @@ export const gmailService = {
     }
   },
 
-  async scanEmails(since = null, until = null, max = 500) {
-    console.log('[Gmail Service] Scanning emails...', { since, until, max });
+  // only: 'all' | 'applied' | 'rejected' | 'sent'
+  async scanEmails(since = null, until = null, max = 500, only = null) {
+    console.log('[Gmail Service] Scanning emails...', { since, until, max, only });
 
     const params = new URLSearchParams();
     if (since) {
@@ -55,6 +57,9 @@ export const gmailService = {
       }
     }
     params.append('limit', max.toString());
+    if (only) {
+      params.append('only', only);
+    }
 
     const url = `/google/scan?${params.toString()}`;
     console.log('[Gmail Service] Scan URL:', url);
@@ -74,5 +79,61 @@ export const gmailService = {
       console.error('[Gmail Service] Scan error:', error);
       throw error;
     }
+  },
+
+  // Phrase packs used to find and classify non-English emails
+  async getLanguages() {
+    const response = await api.get('/google/languages');
+    return response.data;
+  },
+
+  async saveLanguages(languages) {
+    const response = await api.put('/google/languages', { languages });
+    return response.data;
+  },
+
+  // Re-run the current extractors over past imports; apply the diff later
+  async startReclassify() {
+    const response = await api.post('/google/reclassify');
+    return response.data;
+  },
+
+  async getReclassifyProposals() {
+    const response = await api.get('/google/reclassify');
+    return response.data;
+  },
+
+  // ids: proposal ids to apply; omit to apply all
+  async applyReclassify(ids = null) {
+    const body = ids ? { ids } : { all: true };
+    const response = await api.post('/google/reclassify/apply', body);
+    return response.data;
+  },
+
+  async discardReclassify() {
+    const response = await api.delete('/google/reclassify');
+    return response.data;
+  },
+
+  async getQuota() {
+    const response = await api.get('/google/quota');
+    return response.data;
+  },
+
+  // Scheduled syncs, e.g. every 6 hours: cron '0 */6 * * *', payload { days: 2 }
+  async getSchedules() {
+    const response = await api.get('/schedules');
+    return response.data;
+  },
+
+  async scheduleSync(cron, payload = {}) {
+    const timezone = Intl.DateTimeFormat().resolvedOptions().timeZone;
+    const response = await api.post('/schedules', { job_type: 'gmail_initial_sync', payload, cron, timezone });
+    return response.data;
+  },
+
+  async deleteSchedule(id) {
+    const response = await api.delete(`/schedules/${id}`);
+    return response.data;
   }
 };
@@ -28,5 +28,23 @@ export const jobsService = {
   async deleteJob(id) {
     const response = await api.delete(`/jobs/${id}`);
     return response.data;
+  },
+
+  // block: '' | 'sender' | 'domain'
+  async notAJob(id, block = '') {
+    const response = await api.post(`/jobs/${id}/not-a-job`, { block });
+    return response.data;
+  },
+
+  // Syncs, salary lookups and other work queued for the user
+  // filters: { type, status, limit, offset }
+  async getBackgroundJobs(filters = {}) {
+    const response = await api.get('/background-jobs', { params: filters });
+    return response.data;
+  },
+
+  async cancelBackgroundJob(id) {
+    const response = await api.post(`/background-jobs/${id}/cancel`);
+    return response.data;
   }
 };
@@ -6,6 +6,7 @@ export const DEFAULT_PAGINATION = {
 };
 
 export const JOB_STATUSES = [
+	'lead',
 	'wishlist',
 	'applied',
 	'interviewing',
@@ -15,6 +16,7 @@ export const JOB_STATUSES = [
 ];
 
 export const STATUS_COLORS = {
+	lead: 'bg-purple-100 text-purple-800',
 	wishlist: 'bg-gray-100 text-gray-800',
 	applied: 'bg-blue-100 text-blue-800',
 	interviewing: 'bg-yellow-100 text-yellow-800',